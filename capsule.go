@@ -0,0 +1,245 @@
+package gdn
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+// GeminiLayoutFile is the name of the layout template within the ConfigDir
+// used to wrap the pages of a Gemini capsule.
+const GeminiLayoutFile = "layout.gmi"
+
+// DefaultGeminiLayout is the layout used to wrap the pages of a Gemini capsule
+// when the garden does not provide a Gemini layout of its own.
+const DefaultGeminiLayout = `{{.Body}}
+{{with .Backlinks}}
+## Linked from
+
+{{range .}}=> {{.URL}} {{.Name}}
+{{end}}{{end}}
+{{range .Breadcrumbs}}=> {{.URL}} {{.Name}}
+{{end}}`
+
+// defaultGeminiLayout is the parsed DefaultGeminiLayout.
+var defaultGeminiLayout = template.Must( // nolint: gochecknoglobals
+	template.New(GeminiLayoutFile).Parse(DefaultGeminiLayout))
+
+// LoadGeminiLayout loads the Gemini layout template of the garden rooted at the
+// given source directory.  The DefaultGeminiLayout is returned when the garden
+// does not have a Gemini layout file.  The layout is a text/template since
+// Gemini text needs no escaping.
+func LoadGeminiLayout(src string) (*template.Template, error) {
+	return loadGeminiTemplate(src, GeminiLayoutFile, defaultGeminiLayout)
+}
+
+// loadGeminiTemplate loads the named text/template from the ConfigDir of the
+// garden rooted at the given source directory.  The fallback is returned when
+// the garden does not have the template.
+func loadGeminiTemplate(src, name string,
+	fallback *template.Template) (*template.Template, error) {
+	file := filepath.Join(src, ConfigDir, name)
+
+	tmpl, err := template.ParseFiles(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return fallback, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not load template: %s: %w", file, err)
+	}
+
+	return tmpl, nil
+}
+
+// GeminiIndexFile is the name of the text/template within the ConfigDir used to
+// list the contents of a directory that has no index page in a Gemini capsule.
+const GeminiIndexFile = "index.gmi"
+
+// DefaultGeminiIndex is the Gemini index template used when the garden does
+// not provide a Gemini index template of its own.
+const DefaultGeminiIndex = `# {{.Title}}
+{{with .Branches}}
+{{range .}}=> {{.URL}} {{.Name}}/
+{{end}}{{end}}{{with .Leaves}}
+{{range .}}=> {{.URL}} {{.Name}}
+{{end}}{{end}}`
+
+// defaultGeminiIndex is the parsed DefaultGeminiIndex.
+var defaultGeminiIndex = template.Must( // nolint: gochecknoglobals
+	template.New(GeminiIndexFile).Parse(DefaultGeminiIndex))
+
+// LoadGeminiIndex loads the Gemini index template of the garden rooted at the
+// given source directory.  The DefaultGeminiIndex is returned when the garden
+// does not have a Gemini index template.
+func LoadGeminiIndex(src string) (*template.Template, error) {
+	return loadGeminiTemplate(src, GeminiIndexFile, defaultGeminiIndex)
+}
+
+// capsule is a Gemini capsule grown alongside the HTML site.
+type capsule struct {
+	dir    string             // root directory of the capsule
+	layout *template.Template // layout wrapping each Gemini page
+	index  *template.Template // index of directories without an index page
+}
+
+// loadCapsule loads the templates of the garden rooted at the given source
+// directory for a Gemini capsule grown into dir.
+func loadCapsule(src, dir string) (*capsule, error) {
+	layout, err := LoadGeminiLayout(src)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := LoadGeminiIndex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return &capsule{dir: dir, layout: layout, index: index}, nil
+}
+
+// capsuleDir returns the root directory of the capsule being grown, or an
+// empty string when no capsule is grown.
+func (g *grower) capsuleDir() string {
+	if g.capsule == nil {
+		return ""
+	}
+
+	return g.capsule.dir
+}
+
+// CapsuleURL is the path of the leaf's destination within a Gemini capsule.
+// Markdown pages are converted to Gemini pages ending in .gmi, and other files
+// keep their names in a capsule.  It is always slash separated (e.g.
+// /notes/page.gmi).
+func (l Leaf) CapsuleURL() string {
+	if l.Typ == Markdown && !l.Symlink {
+		return filepath.ToSlash(ChExt(l.Path, ".gmi"))
+	}
+
+	return filepath.ToSlash(l.Path)
+}
+
+// capsuleDst is the destination file path for the leaf in the Gemini capsule.
+func (l Leaf) capsuleDst(g *grower) string {
+	return filepath.Join(g.capsule.dir, filepath.FromSlash(l.CapsuleURL()))
+}
+
+// growCapsule writes the leaf into the Gemini capsule.  Gemini pages are
+// wrapped in the Gemini layout with their links to Markdown pages pointed at
+// the converted pages, and so are Markdown pages once they are converted to
+// Gemini text.  Other files are copied as they are.
+func (l Leaf) growCapsule(g *grower) error {
+	dst := l.capsuleDst(g)
+
+	if l.Symlink {
+		return CopyLink(l.Src, dst)
+	}
+
+	if l.Typ != Gemini && l.Typ != Markdown {
+		if err := CopyFile(l.Src, dst); err != nil {
+			return fmt.Errorf("error copying %s to %s: %w", l.Src, dst, err)
+		}
+
+		return nil
+	}
+
+	src, err := ioutil.ReadFile(l.Src)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", l.Src, err)
+	}
+
+	page, err := l.page(g, g.titles[l.Path], (*Leaf).CapsuleURL)
+	if err != nil {
+		return err
+	}
+
+	_, src = ParseMeta(src)
+
+	if l.Typ == Markdown {
+		var gemini bytes.Buffer
+		if err := MarkdownToGemini(&gemini, src); err != nil {
+			return fmt.Errorf("error converting %s: %w", l.Src, err)
+		}
+
+		src = gemini.Bytes()
+	} else if src, err = capsuleLinks(src); err != nil {
+		return fmt.Errorf("error reading %s: %w", l.Src, err)
+	}
+
+	page.Body = geminiBody(src)
+
+	return writePage(g.capsule.layout, page, dst)
+}
+
+// capsuleLinks points the link lines of the Gemini text that go to Markdown
+// pages at the Gemini pages they are converted to in the capsule.
+func capsuleLinks(src []byte) ([]byte, error) {
+	doc, err := gmi.Parse(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, line := range doc.Lines {
+		link, ok := line.(gmi.LinkLine)
+		if !ok {
+			continue
+		}
+
+		u, err := url.Parse(link.URL)
+		if err != nil || TypeByExtension(path.Ext(u.Path)) != Markdown {
+			continue
+		}
+
+		link.URL = retargetLink(link.URL, ".gmi")
+		doc.Lines[i] = link
+	}
+
+	return []byte(doc.String()), nil
+}
+
+// geminiBody returns Gemini text as the body of a page for the Gemini layout.
+// The Gemini layout is a text/template, so the body is never escaped.
+func geminiBody(src []byte) htmltemplate.HTML {
+	return htmltemplate.HTML(strings.TrimRight(string(src), "\r\n"))
+}
+
+// growCapsuleIndex generates a Gemini index page listing the contents of the
+// branch in the capsule, unless the branch already has a Gemini index page.
+func (b Branch) growCapsuleIndex(g *grower) error {
+	if b.hasLeaf(path.Join(b.URL(), GeminiIndexFile), (*Leaf).CapsuleURL) {
+		return nil
+	}
+
+	idx, err := b.index(g, (*Leaf).CapsuleURL)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if err := g.capsule.index.Execute(&body, idx); err != nil {
+		return fmt.Errorf("error applying index to %s: %w", b.Src, err)
+	}
+
+	page := Page{
+		Title:       idx.Title,
+		Body:        geminiBody(body.Bytes()),
+		Path:        path.Join(idx.Path, GeminiIndexFile),
+		Breadcrumbs: Breadcrumbs(path.Clean(idx.Path)),
+		Modified:    idx.Modified,
+		Site:        g.site,
+	}
+
+	dst := filepath.Join(g.capsule.dir, filepath.FromSlash(page.Path))
+
+	return writePage(g.capsule.layout, page, dst)
+}
@@ -0,0 +1,115 @@
+package gdn_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestGrowCapsule(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	t.Log("+test that growing testdata/src matches testdata/capsule")
+
+	site := filepath.Join(tmp, "site")
+	capsule := filepath.Join(tmp, "capsule")
+	root := gdn.NewTree(testsrc, site)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	if err := root.GrowWith(gdn.GrowOptions{Capsule: capsule}); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	matchDir(t, site, "testdata/expected")
+	matchDir(t, capsule, "testdata/capsule")
+}
+
+func TestGrowCapsuleLayout(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	capsule := filepath.Join(tmp, "capsule")
+
+	writeFile(t, filepath.Join(src, "a.gmi"), "# Page A\n=> notes/b.gmi\n")
+	writeFile(t, filepath.Join(src, "notes", "b.gmi"), "# Page B\n")
+
+	root := gdn.NewTree(src, filepath.Join(tmp, "site"))
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	t.Log("+test the default Gemini layout adds backlinks and navigation")
+
+	if err := root.GrowWith(gdn.GrowOptions{Capsule: capsule}); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	expected := "# Page B\n\n## Linked from\n\n=> /a.gmi Page A\n\n" +
+		"=> / Home\n=> /notes/ notes\n"
+	if page := readFile(t, filepath.Join(capsule, "notes", "b.gmi")); page !=
+		expected {
+		t.Errorf("page gave: %q, expecting: %q", page, expected)
+	}
+
+	t.Log("+test a Gemini layout provided by the garden")
+
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.GeminiLayoutFile),
+		"{{.Title}} <{{.Path}}>\n{{.Body}}\n")
+
+	if err := root.GrowWith(gdn.GrowOptions{Capsule: capsule}); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	expected = "Page B </notes/b.gmi>\n# Page B\n"
+	if page := readFile(t, filepath.Join(capsule, "notes", "b.gmi")); page !=
+		expected {
+		t.Errorf("page gave: %q, expecting: %q", page, expected)
+	}
+}
+
+func TestGrowCapsuleLinks(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	capsule := filepath.Join(tmp, "capsule")
+
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.GeminiLayoutFile),
+		"{{.Body}}\n")
+	writeFile(t, filepath.Join(src, "a.gmi"), "# Page A\n"+
+		"=> notes/b.md#part Page B\n=> c.gmi\n=> cat.png\n"+
+		"=> https://example.tld/d.md\n```\n=> e.md\n```\n")
+	writeFile(t, filepath.Join(src, "notes", "b.md"), "# Page B\n")
+	writeFile(t, filepath.Join(src, "c.gmi"), "# Page C\n")
+	writeFile(t, filepath.Join(src, "cat.png"), "meow")
+
+	root := gdn.NewTree(src, filepath.Join(tmp, "site"))
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	if err := root.GrowWith(gdn.GrowOptions{Capsule: capsule}); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	t.Log("+test links to Markdown pages point at the converted pages")
+
+	expected := "# Page A\n=> notes/b.gmi#part Page B\n=> c.gmi\n" +
+		"=> cat.png\n=> https://example.tld/d.md\n```\n=> e.md\n```\n"
+	if page := readFile(t, filepath.Join(capsule, "a.gmi")); page != expected {
+		t.Errorf("page gave: %q, expecting: %q", page, expected)
+	}
+
+	if !pathIsRegularFile(t, filepath.Join(capsule, "notes", "b.gmi")) {
+		t.Errorf("the Markdown page was not converted into the capsule")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+// defaultOut is the default output directory for the generated site.
+const defaultOut = "dist"
+
+func buildCommand() command {
+	return command{
+		name:    "build",
+		summary: "Grow the garden into a static website.",
+		setup: func(fs *flag.FlagSet, stdout io.Writer) func([]string) error {
+			src := fs.String("src", ".", "source directory of the garden")
+			out := fs.String("out", defaultOut, "output directory for the site")
+			capsule := fs.String("capsule", "",
+				"also grow a Gemini capsule into this directory")
+			sort := fs.String("sort", gdn.SortByName.String(),
+				"sort generated indexes by name, path or modified")
+			incremental := fs.Bool("incremental", false,
+				"only grow what changed since the last incremental build")
+			workers := fs.Int("workers", runtime.NumCPU(),
+				"number of files to grow at the same time")
+			drafts := fs.Bool("drafts", false, "include pages marked as drafts")
+			symlinks := fs.String("symlinks", gdn.FollowSymlinks.String(),
+				"follow, skip or copy symbolic links in the garden")
+			toc := fs.Bool("toc", false,
+				"show a table of contents on every page")
+			feed := fs.Bool("feed", false,
+				"write Atom and Gemini feeds of recently updated pages")
+			feedSize := fs.Int("feed-size", gdn.DefaultFeedSize,
+				"number of pages listed in the feeds")
+			siteURL := fs.String("site-url", "",
+				"URL the site is published at, for links in the Atom feed")
+			strict := fs.Bool("strict", false,
+				"fail without growing anything if a page has a broken link")
+			prune := fs.Bool("prune", false, "remove files from the output "+
+				"directories that were not grown from the garden")
+			dryRun := fs.Bool("dry-run", false,
+				"list the files --prune would remove without removing them")
+			fingerprint := fs.Bool("fingerprint", false, "put a hash of their "+
+				"contents in the names of images, styles and scripts")
+
+			return func(args []string) error {
+				if err := noArgs(args); err != nil {
+					return err
+				}
+
+				cfg, err := configure(fs, *src)
+				if err != nil {
+					return err
+				}
+
+				by, err := parseIndexSort(*sort)
+				if err != nil {
+					return err
+				}
+
+				policy, err := parseSymlinks(*symlinks)
+				if err != nil {
+					return err
+				}
+
+				opts := cfg.GrowOptions()
+				opts.Capsule = *capsule
+				opts.IndexSort = by
+				opts.Incremental = *incremental
+				opts.Workers = *workers
+				opts.TOC = *toc
+				opts.Feed = *feed
+				opts.FeedSize = *feedSize
+				opts.Site.URL = *siteURL
+				opts.Strict = *strict
+				opts.Prune = *prune && !*dryRun
+				opts.Fingerprint = *fingerprint
+
+				scan := cfg.ScanOptions()
+				scan.Drafts = *drafts
+				scan.Symlinks = policy
+				scan.Exclude = append(scan.Exclude, *out, *capsule)
+
+				if err := build(*src, *out, scan, opts); err != nil {
+					return err
+				}
+
+				fmt.Fprintf(stdout, "grew %s into %s\n", *src, *out)
+
+				if *capsule != "" {
+					fmt.Fprintf(stdout, "grew %s into %s\n", *src, *capsule)
+				}
+
+				if *dryRun {
+					return listStale(stdout, *src, *out, scan, opts)
+				}
+
+				return nil
+			}
+		},
+	}
+}
+
+// build scans the source directory and grows it into the output directory.
+// Growing stops if gdn is interrupted.
+func build(src, out string, scan gdn.ScanOptions, opts gdn.GrowOptions) error {
+	root := gdn.NewTree(src, out)
+
+	if err := root.ScanWith(scan); err != nil {
+		return fmt.Errorf("could not scan %s: %w", src, err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if err := root.GrowContext(ctx, opts); err != nil {
+		return fmt.Errorf("could not grow %s: %w", out, err)
+	}
+
+	return nil
+}
+
+// listStale writes the files that pruning the output directories would remove.
+func listStale(w io.Writer, src, out string, scan gdn.ScanOptions,
+	opts gdn.GrowOptions) error {
+	root := gdn.NewTree(src, out)
+
+	if err := root.ScanWith(scan); err != nil {
+		return fmt.Errorf("could not scan %s: %w", src, err)
+	}
+
+	stale, err := root.Stale(opts)
+	if err != nil {
+		return fmt.Errorf("could not prune %s: %w", out, err)
+	}
+
+	for _, p := range stale {
+		fmt.Fprintf(w, "would remove %s\n", p)
+	}
+
+	return nil
+}
+
+// parseIndexSort returns the IndexSort with the given name.
+func parseIndexSort(name string) (gdn.IndexSort, error) {
+	var by gdn.IndexSort
+	if err := by.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("%v: %w", err, errUsage)
+	}
+
+	return by, nil
+}
+
+// parseSymlinks returns the SymlinkPolicy with the given name.
+func parseSymlinks(name string) (gdn.SymlinkPolicy, error) {
+	var policy gdn.SymlinkPolicy
+	if err := policy.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("%v: %w", err, errUsage)
+	}
+
+	return policy, nil
+}
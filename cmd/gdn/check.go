@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"git.sr.ht/~kiba/gdn"
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+// errUnhealthy occurs when checking the garden finds errors.
+var errUnhealthy = errors.New("garden has errors")
+
+// linkTimeout is how long to wait for a link to another site to respond.
+const linkTimeout = 10 * time.Second
+
+func checkCommand() command {
+	return command{
+		name: "check",
+		summary: "Check the pages of the garden for problems and that it " +
+			"grows without errors.",
+		setup: func(fs *flag.FlagSet, stdout io.Writer) func([]string) error {
+			src := fs.String("src", ".", "source directory of the garden")
+			asJSON := fs.Bool("json", false, "write the problems found as JSON")
+			external := fs.Bool("external", false,
+				"also check that HTTP and HTTPS links can be reached")
+
+			return func(args []string) error {
+				if err := noArgs(args); err != nil {
+					return err
+				}
+
+				cfg, err := configure(fs, *src)
+				if err != nil {
+					return err
+				}
+
+				// The problems found are reported even when the garden
+				// could not be grown, so they are not lost.
+				diags, checkErr := check(*src, cfg, *external)
+
+				if err := report(stdout, diags, *asJSON); err != nil {
+					return err
+				}
+
+				if checkErr != nil {
+					return checkErr
+				}
+
+				for _, d := range diags {
+					if d.Severity == gmi.SeverityError {
+						return errUnhealthy
+					}
+				}
+
+				if !*asJSON {
+					fmt.Fprintf(stdout, "%s is healthy\n", *src)
+				}
+
+				return nil
+			}
+		},
+	}
+}
+
+// check lints the pages of the garden and checks their links, then grows it
+// into a temporary directory which is removed afterwards.  Links to other sites
+// are only checked when external is set.  It returns the problems found, along
+// with the error growing the garden if it fails to grow.
+func check(src string, cfg gdn.Config,
+	external bool) ([]gmi.Diagnostic, error) {
+	tmp, err := ioutil.TempDir("", "gdn-check")
+	if err != nil {
+		return nil, fmt.Errorf("could not make temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	root := gdn.NewTree(src, tmp)
+
+	if err := root.ScanWith(cfg.ScanOptions()); err != nil {
+		return nil, fmt.Errorf("could not scan %s: %w", src, err)
+	}
+
+	diags, err := root.Lint()
+	if err != nil {
+		return nil, fmt.Errorf("could not check %s: %w", src, err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var opts gdn.CheckLinksOptions
+	if external {
+		client := &http.Client{Timeout: linkTimeout}
+		opts.External = gdn.HTTPResolver(client)
+	}
+
+	broken, err := root.CheckLinks(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not check links in %s: %w", src, err)
+	}
+
+	diags = append(diags, broken...)
+
+	// The broken links were already found, and only the site is grown.
+	grow := cfg.GrowOptions()
+	grow.Capsule = ""
+	grow.Incremental = false
+	grow.Strict = false
+
+	return diags, build(src, tmp, cfg.ScanOptions(), grow)
+}
+
+// report writes the problems found, one per line or as a JSON array.
+func report(w io.Writer, diags []gmi.Diagnostic, asJSON bool) error {
+	if !asJSON {
+		for _, d := range diags {
+			fmt.Fprintln(w, d)
+		}
+
+		return nil
+	}
+
+	if diags == nil {
+		diags = []gmi.Diagnostic{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+
+	if err := enc.Encode(diags); err != nil {
+		return fmt.Errorf("could not write JSON: %w", err)
+	}
+
+	return nil
+}
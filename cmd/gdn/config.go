@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+// configure loads the ConfigFile of the garden rooted at the source directory
+// and sets each flag that was not given on the command line to its value in
+// the file, so flags override the file.  The extensions in the file are added
+// to the DefaultRegistry.
+func configure(fs *flag.FlagSet, src string) (gdn.Config, error) {
+	cfg, err := gdn.LoadConfig(src)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Register(gdn.DefaultRegistry); err != nil {
+		return cfg, fmt.Errorf("invalid extensions in %s: %w",
+			gdn.ConfigFile, err)
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	for name, value := range configFlags(cfg) {
+		if given[name] || fs.Lookup(name) == nil {
+			continue
+		}
+
+		if err := fs.Set(name, value); err != nil {
+			return cfg, fmt.Errorf("invalid %s in %s: %w",
+				name, gdn.ConfigFile, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// configFlags returns the values of the flags that are set in the config, by
+// the name of the flag.
+func configFlags(cfg gdn.Config) map[string]string {
+	flags := make(map[string]string)
+	set := func(name, value string, ok bool) {
+		if ok {
+			flags[name] = value
+		}
+	}
+
+	set("out", cfg.Out, cfg.Out != "")
+	set("capsule", cfg.Capsule, cfg.Capsule != "")
+	set("site-url", cfg.URL, cfg.URL != "")
+	set("sort", cfg.Sort.String(), cfg.Sort != gdn.SortByName)
+	set("symlinks", cfg.Symlinks.String(), cfg.Symlinks != gdn.FollowSymlinks)
+	set("feed-size", strconv.Itoa(cfg.FeedSize), cfg.FeedSize > 0)
+
+	for name, on := range map[string]bool{
+		"drafts":      cfg.Drafts,
+		"toc":         cfg.TOC,
+		"feed":        cfg.Feed,
+		"incremental": cfg.Incremental,
+		"strict":      cfg.Strict,
+		"prune":       cfg.Prune,
+		"fingerprint": cfg.Fingerprint,
+	} {
+		set(name, "true", on)
+	}
+
+	return flags
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestConfigure(t *testing.T) {
+	tmp, err := ioutil.TempDir(".", "tmp")
+	if err != nil {
+		t.Fatalf("could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "garden")
+	if err := os.MkdirAll(src, 0o755); err != nil {
+		t.Fatalf("could not make %s: %v", src, err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(src, gdn.ConfigFile), []byte(`{
+	"out": "../public",
+	"sort": "modified",
+	"toc": true,
+	"feed": true
+}`), 0o644)
+	if err != nil {
+		t.Fatalf("could not write %s: %v", gdn.ConfigFile, err)
+	}
+
+	tbls := []struct {
+		name string
+		args []string
+		out  string
+		sort string
+		toc  bool
+		feed bool
+	}{
+		{
+			"file sets flags that are not given",
+			nil,
+			filepath.Join(src, "..", "public"), "modified", true, true,
+		},
+		{
+			"flags override the file",
+			[]string{"--out", "site", "--sort", "path", "--toc=false"},
+			"site", "path", false, true,
+		},
+	}
+
+	for _, tbl := range tbls {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		out := fs.String("out", defaultOut, "")
+		sort := fs.String("sort", gdn.SortByName.String(), "")
+		toc := fs.Bool("toc", false, "")
+		feed := fs.Bool("feed", false, "")
+
+		if err := fs.Parse(tbl.args); err != nil {
+			t.Fatalf("%s: could not parse flags: %v", tbl.name, err)
+		}
+
+		if _, err := configure(fs, src); err != nil {
+			t.Fatalf("%s: configure encountered an unexpected error: %v",
+				tbl.name, err)
+		}
+
+		if *out != tbl.out {
+			t.Errorf("%s: out gave: %s, expecting: %s", tbl.name, *out, tbl.out)
+		}
+
+		if *sort != tbl.sort {
+			t.Errorf("%s: sort gave: %s, expecting: %s",
+				tbl.name, *sort, tbl.sort)
+		}
+
+		if *toc != tbl.toc || *feed != tbl.feed {
+			t.Errorf("%s: toc and feed gave: %v %v, expecting: %v %v",
+				tbl.name, *toc, *feed, tbl.toc, tbl.feed)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func convertCommand() command {
+	return command{
+		name:    "convert",
+		args:    "<file>",
+		summary: "Convert a page between Gemini text and Markdown.",
+		help: "Gemini text is converted to Markdown, and Markdown to Gemini " +
+			"text.  Use - as the\nfile to read from standard input.",
+		setup: func(fs *flag.FlagSet, stdout io.Writer) func([]string) error {
+			to := fs.String("to", "", "format to convert to, gemini or "+
+				"markdown (default the other format of the file)")
+			out := fs.String("out", "", "file to write the page to "+
+				"(default standard output)")
+
+			return func(args []string) error {
+				if len(args) != 1 {
+					return fmt.Errorf("expected one file: %w", errUsage)
+				}
+
+				typ, err := convertTo(args[0], *to)
+				if err != nil {
+					return err
+				}
+
+				src, err := readInput(args[0])
+				if err != nil {
+					return err
+				}
+
+				var page bytes.Buffer
+				if err := convert(&page, src, typ); err != nil {
+					return err
+				}
+
+				if *out == "" {
+					if _, err := page.WriteTo(stdout); err != nil {
+						return fmt.Errorf("could not write page: %w", err)
+					}
+
+					return nil
+				}
+
+				err = ioutil.WriteFile(*out, page.Bytes(), gdn.LeafPerm)
+				if err != nil {
+					return fmt.Errorf("could not write %s: %w", *out, err)
+				}
+
+				return nil
+			}
+		},
+	}
+}
+
+// convertTo returns the type of file to convert the named file to.  When to is
+// empty, a Gemini file is converted to Markdown and a Markdown file to Gemini.
+func convertTo(name, to string) (gdn.FileType, error) {
+	switch strings.ToLower(to) {
+	case "gemini", "gmi":
+		return gdn.Gemini, nil
+	case "markdown", "md":
+		return gdn.Markdown, nil
+	case "":
+	default:
+		return gdn.Unknown, fmt.Errorf("unknown format %q: %w", to, errUsage)
+	}
+
+	switch gdn.TypeByExtension(filepath.Ext(name)) {
+	case gdn.Gemini:
+		return gdn.Markdown, nil
+	case gdn.Markdown:
+		return gdn.Gemini, nil
+	case gdn.Unknown:
+	}
+
+	return gdn.Unknown,
+		fmt.Errorf("set --to for %s since it is not a page: %w", name, errUsage)
+}
+
+// readInput reads the named file, or standard input when the name is -.
+func readInput(name string) ([]byte, error) {
+	var (
+		src []byte
+		err error
+	)
+
+	if name == "-" {
+		src, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		src, err = ioutil.ReadFile(name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+
+	return src, nil
+}
+
+// convert writes the page converted to the type of file.
+func convert(w io.Writer, src []byte, to gdn.FileType) error {
+	if to == gdn.Markdown {
+		return gdn.GeminiToMarkdown(w, src)
+	}
+
+	return gdn.MarkdownToGemini(w, src)
+}
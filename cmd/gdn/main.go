@@ -1,25 +1,177 @@
+// Command gdn grows a digital garden of Gemini text into a static website.
+//
+// Usage:
+//
+//     gdn <command> [flags] [arguments]
+//
+// Run `gdn help` for the list of commands, or `gdn <command> --help` for the
+// flags of a command.
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"os/signal"
+)
 
-	"git.sr.ht/~kiba/gdn"
+// Exit codes returned by gdn.
+const (
+	exitOK    = 0 // the command succeeded
+	exitFail  = 1 // the command failed
+	exitUsage = 2 // the command was used incorrectly
 )
 
+// errUsage occurs when a command is given the wrong arguments.
+var errUsage = errors.New("invalid usage")
+
+// command is a subcommand of gdn.
+type command struct {
+	name    string // name used to run the command
+	args    string // arguments shown in the usage after the flags
+	summary string // one line description of the command
+	help    string // more about the command, shown by --help
+	// setup defines the flags of the command and returns the function to run
+	// it with the remaining arguments once the flags are parsed.
+	setup func(fs *flag.FlagSet, stdout io.Writer) func(args []string) error
+}
+
+// commands returns all of the subcommands of gdn in the order they are listed
+// in the usage.
+func commands() []command {
+	return []command{
+		buildCommand(),
+		serveCommand(),
+		newCommand(),
+		checkCommand(),
+		convertCommand(),
+		versionCommand(),
+	}
+}
+
 func main() {
-	dir, err := os.Getwd()
-	if err != nil {
-		log.Fatal(err)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs gdn with the given arguments and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+
+	name := args[0]
+
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return exitOK
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return runCommand(cmd, args[1:], stdout, stderr)
+		}
+	}
+
+	fmt.Fprintf(stderr, "gdn: unknown command %q\n\n", name)
+	usage(stderr)
+
+	return exitUsage
+}
+
+// runCommand parses the flags for the command, runs it, and returns the exit
+// code.
+func runCommand(cmd command, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gdn "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	exec := cmd.setup(fs, stdout)
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gdn %s [flags]", cmd.name)
+
+		if cmd.args != "" {
+			fmt.Fprintf(fs.Output(), " %s", cmd.args)
+		}
+
+		fmt.Fprintf(fs.Output(), "\n\n%s\n", cmd.summary)
+
+		if cmd.help != "" {
+			fmt.Fprintf(fs.Output(), "\n%s\n", cmd.help)
+		}
+
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+
+		if hasFlags {
+			fmt.Fprintln(fs.Output(), "\nFlags:")
+			fs.PrintDefaults()
+		}
+	}
+
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		// The usage was already written by Parse.
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+
+	if err := exec(fs.Args()); errors.Is(err, errUsage) {
+		fmt.Fprintf(stderr, "gdn %s: %v\n\n", cmd.name, err)
+		fs.Usage()
+
+		return exitUsage
+	} else if err != nil {
+		fmt.Fprintf(stderr, "gdn %s: %v\n", cmd.name, err)
+		return exitFail
 	}
 
-	root := gdn.NewTree(dir, "dist")
+	return exitOK
+}
+
+// usage writes the usage of gdn listing all of its commands.
+func usage(w io.Writer) {
+	fmt.Fprint(w, "Usage: gdn <command> [flags] [arguments]\n\n")
+	fmt.Fprint(w, "gdn grows a digital garden of Gemini text into a static "+
+		"website.\n\nCommands:\n")
+
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+
+	fmt.Fprint(w, "\nRun `gdn <command> --help` for the flags of a command.\n")
+}
 
-	if err := root.Scan(); err != nil {
-		log.Fatal(err)
+// noArgs returns errUsage if any arguments are given.
+func noArgs(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected arguments: %q: %w", args, errUsage)
 	}
 
-	if err := root.Grow(); err != nil {
-		log.Fatal(err)
+	return nil
+}
+
+// interruptContext returns a context that is canceled when gdn receives an
+// interrupt signal.  Call stop to release the resources of the context once it
+// is no longer needed.
+func interruptContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sig)
+		cancel()
 	}
 }
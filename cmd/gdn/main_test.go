@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tmp, err := ioutil.TempDir(".", "tmp")
+	if err != nil {
+		t.Fatalf("could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	if err := os.MkdirAll(src, 0o755); err != nil {
+		t.Fatalf("could not make %s: %v", src, err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(src, "index.gmi"), []byte("# Home\n"),
+		0o644)
+	if err != nil {
+		t.Fatalf("could not write index.gmi: %v", err)
+	}
+
+	tbls := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string // expected at the start of the output, if any
+		stderr string // expected at the start of the errors, if any
+	}{
+		{"no command", nil, exitUsage, "", "Usage: gdn <command>"},
+		{"help", []string{"help"}, exitOK, "Usage: gdn <command>", ""},
+		{"--help", []string{"--help"}, exitOK, "Usage: gdn <command>", ""},
+		{
+			"unknown command", []string{"nope"}, exitUsage, "",
+			"gdn: unknown command \"nope\"",
+		},
+		{"version", []string{"version"}, exitOK, "gdn ", ""},
+		{
+			"extra arguments", []string{"version", "x"}, exitUsage, "",
+			"gdn version: unexpected arguments",
+		},
+		{
+			"command --help", []string{"build", "--help"}, exitOK, "",
+			"Usage: gdn build [flags]",
+		},
+		{
+			"unknown flag", []string{"build", "--nope"}, exitUsage, "",
+			"flag provided but not defined: -nope",
+		},
+		{
+			"missing argument", []string{"convert"}, exitUsage, "",
+			"gdn convert: expected one file",
+		},
+		{
+			"command fails",
+			[]string{"build", "--src", filepath.Join(tmp, "missing")},
+			exitFail, "", "gdn build: could not scan",
+		},
+		{
+			"command succeeds",
+			[]string{"build", "--src", src, "--out", filepath.Join(tmp, "out")},
+			exitOK, "grew " + src, "",
+		},
+	}
+
+	for _, tbl := range tbls {
+		var stdout, stderr bytes.Buffer
+
+		if code := run(tbl.args, &stdout, &stderr); code != tbl.code {
+			t.Errorf("%s: exit code gave: %d, expecting: %d (%s)",
+				tbl.name, code, tbl.code, stderr.String())
+		}
+
+		if !strings.HasPrefix(stdout.String(), tbl.stdout) ||
+			(tbl.stdout == "" && stdout.Len() != 0) {
+			t.Errorf("%s: output gave: %q, expecting: %q",
+				tbl.name, stdout.String(), tbl.stdout)
+		}
+
+		if !strings.HasPrefix(stderr.String(), tbl.stderr) ||
+			(tbl.stderr == "" && stderr.Len() != 0) {
+			t.Errorf("%s: errors gave: %q, expecting: %q",
+				tbl.name, stderr.String(), tbl.stderr)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+// errNotEmpty occurs when creating a garden in a directory that has files.
+var errNotEmpty = errors.New("directory is not empty")
+
+// indexGemini is the first page of a new garden.
+const indexGemini = `# My Garden
+
+Welcome to my digital garden.
+`
+
+func newCommand() command {
+	return command{
+		name:    "new",
+		args:    "<dir>",
+		summary: "Plant a new garden with an index page and a layout.",
+		setup: func(fs *flag.FlagSet, stdout io.Writer) func([]string) error {
+			return func(args []string) error {
+				if len(args) != 1 {
+					return fmt.Errorf("expected one directory: %w", errUsage)
+				}
+
+				if err := plant(args[0]); err != nil {
+					return err
+				}
+
+				fmt.Fprintf(stdout, "planted a new garden in %s\n", args[0])
+
+				return nil
+			}
+		},
+	}
+}
+
+// plant creates a new garden in the directory.  The directory must not exist
+// or be empty.
+func plant(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not read %s: %w", dir, err)
+	}
+
+	if len(files) != 0 {
+		return fmt.Errorf("could not plant in %s: %w", dir, errNotEmpty)
+	}
+
+	cfg := filepath.Join(dir, gdn.ConfigDir)
+	if err := os.MkdirAll(cfg, gdn.BranchPerm); err != nil {
+		return fmt.Errorf("could not make directory %s: %w", cfg, err)
+	}
+
+	seeds := map[string]string{
+		filepath.Join(dir, "index.gmi"):    indexGemini,
+		filepath.Join(cfg, gdn.LayoutFile): gdn.DefaultLayout,
+	}
+
+	for path, contents := range seeds {
+		err := ioutil.WriteFile(path, []byte(contents), gdn.LeafPerm)
+		if err != nil {
+			return fmt.Errorf("could not write %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
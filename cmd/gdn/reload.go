@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadPath is the path of the server-sent events that tell open pages to
+// reload.  Being hidden, it never clashes with a file in the garden.
+const reloadPath = "/.gdn/reload"
+
+// reloadScript is added to the end of every HTML page served by gdn serve to
+// reload the page when the garden is grown again.
+const reloadScript = `<script>new EventSource("` + reloadPath +
+	`").onmessage = function () { location.reload(); };</script>
+`
+
+// broadcaster sends server-sent events to every connected page telling them to
+// reload.
+type broadcaster struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{clients: make(map[chan struct{}]bool)}
+}
+
+// broadcast tells every connected page to reload.
+func (b *broadcaster) broadcast() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for c := range b.clients {
+		select {
+		case c <- struct{}{}:
+		default: // a reload is already pending
+		}
+	}
+}
+
+// ServeHTTP streams the reload events to a page until it disconnects.
+func (b *broadcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported",
+			http.StatusInternalServerError)
+		return
+	}
+
+	c := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	b.clients[c] = true
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// injectReload serves the files in the directory, adding the reloadScript to
+// every HTML page.
+func injectReload(dir http.Dir) http.Handler {
+	files := http.FileServer(dir)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path
+		if strings.HasSuffix(name, "/") {
+			name += "index.html"
+		}
+
+		if path.Ext(name) != ".html" {
+			files.ServeHTTP(w, r)
+			return
+		}
+
+		f, err := dir.Open(name)
+		if err != nil {
+			files.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+
+		page, err := ioutil.ReadAll(f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if i := bytes.LastIndex(page, []byte("</body>")); i != -1 {
+			script := append([]byte(reloadScript), page[i:]...)
+			page = append(page[:i:i], script...)
+		} else {
+			page = append(page, reloadScript...)
+		}
+
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(page))
+	})
+}
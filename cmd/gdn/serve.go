@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"git.sr.ht/~kiba/gdn"
+	"git.sr.ht/~kiba/gdn/gemini"
+)
+
+// pollInterval is how often the source of the garden is checked for changes.
+const pollInterval = 500 * time.Millisecond
+
+// Default addresses to serve the garden at.
+const (
+	httpAddr   = "localhost:8080"
+	geminiAddr = "localhost:1965"
+)
+
+func serveCommand() command {
+	return command{
+		name: "serve",
+		summary: "Grow the garden and serve it, growing it again when it " +
+			"changes.",
+		help: "The website is served over HTTP, or the capsule over the " +
+			"Gemini protocol with\n--gemini.  When the garden changes, it " +
+			"is grown again and open pages reload.",
+		setup: func(fs *flag.FlagSet, stdout io.Writer) func([]string) error {
+			src := fs.String("src", ".", "source directory of the garden")
+			out := fs.String("out", "",
+				"output directory for the site (default a temporary directory)")
+			addr := fs.String("addr", "", "address to listen on "+
+				"(default "+httpAddr+", or "+geminiAddr+" with --gemini)")
+			gem := fs.Bool("gemini", false,
+				"serve a Gemini capsule over the Gemini protocol instead")
+			capsule := fs.String("capsule", "", "output directory for the "+
+				"capsule with --gemini (default a temporary directory)")
+			drafts := fs.Bool("drafts", false, "include pages marked as drafts")
+			symlinks := fs.String("symlinks", gdn.FollowSymlinks.String(),
+				"follow, skip or copy symbolic links in the garden")
+
+			return func(args []string) error {
+				if err := noArgs(args); err != nil {
+					return err
+				}
+
+				cfg, err := configure(fs, *src)
+				if err != nil {
+					return err
+				}
+
+				policy, err := parseSymlinks(*symlinks)
+				if err != nil {
+					return err
+				}
+
+				p := &preview{
+					cfg:      cfg,
+					src:      *src,
+					out:      *out,
+					gemini:   *gem,
+					capsule:  *capsule,
+					drafts:   *drafts,
+					symlinks: policy,
+					stdout:   stdout,
+					stderr:   fs.Output(),
+					reload:   newBroadcaster(),
+				}
+
+				return p.serve(*addr)
+			}
+		},
+	}
+}
+
+// preview grows a garden and serves it, growing it again when it changes.
+type preview struct {
+	cfg      gdn.Config        // configuration of the garden
+	src      string            // source directory of the garden
+	out      string            // output directory the site is grown into
+	gemini   bool              // whether to serve the capsule over Gemini
+	capsule  string            // output directory the capsule is grown into
+	drafts   bool              // whether to include pages marked as drafts
+	symlinks gdn.SymlinkPolicy // how symbolic links in the garden are handled
+	stdout   io.Writer         // where progress is written
+	stderr   io.Writer         // where errors growing the garden are written
+	reload   *broadcaster      // tells open pages to reload
+}
+
+// serve grows the garden and serves it at the address until gdn is
+// interrupted.
+func (p *preview) serve(addr string) error {
+	cleanup, err := orTempDir(&p.out)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if p.gemini {
+		cleanup, err := orTempDir(&p.capsule)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+	}
+
+	if err := p.grow(); err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	go p.watch(ctx)
+
+	if p.gemini {
+		if addr == "" {
+			addr = geminiAddr
+		}
+
+		return p.serveGemini(ctx, addr)
+	}
+
+	if addr == "" {
+		addr = httpAddr
+	}
+
+	return p.serveHTTP(ctx, addr)
+}
+
+// serveHTTP serves the site over HTTP until the context is done.
+func (p *preview) serveHTTP(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(reloadPath, p.reload)
+	mux.Handle("/", injectReload(http.Dir(p.out)))
+
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background()) // nolint: errcheck // exiting
+	}()
+
+	fmt.Fprintf(p.stdout, "serving %s at http://%s/\n", p.src, addr)
+
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("could not serve %s: %w", p.out, err)
+	}
+
+	return nil
+}
+
+// serveGemini serves the capsule over the Gemini protocol until the context is
+// done.  The server uses a self-signed certificate for the host of the address.
+func (p *preview) serveGemini(ctx context.Context, addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+
+	if host == "" {
+		host = "localhost"
+	}
+
+	cert, err := gemini.NewCertificate(host)
+	if err != nil {
+		return err
+	}
+
+	server := &gemini.Server{
+		Addr:      addr,
+		Root:      p.capsule,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close() // nolint: errcheck // exiting
+	}()
+
+	fmt.Fprintf(p.stdout, "serving %s at gemini://%s/\n", p.src, addr)
+
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, gemini.ErrServerClosed) {
+		return fmt.Errorf("could not serve %s: %w", p.capsule, err)
+	}
+
+	return nil
+}
+
+// orTempDir sets the directory to a new temporary directory if it is empty.
+// The returned function removes the temporary directory.
+func orTempDir(dir *string) (func(), error) {
+	if *dir != "" {
+		return func() {}, nil
+	}
+
+	tmp, err := ioutil.TempDir("", "gdn-serve")
+	if err != nil {
+		return nil, fmt.Errorf("could not make temporary directory: %w", err)
+	}
+
+	*dir = tmp
+
+	return func() { os.RemoveAll(tmp) }, nil
+}
+
+// grow grows the garden incrementally, so only the leaves that changed since
+// the last time are grown again.
+func (p *preview) grow() error {
+	scan := p.cfg.ScanOptions()
+	scan.Drafts = p.drafts
+	scan.Symlinks = p.symlinks
+	scan.Exclude = append(scan.Exclude, p.out, p.capsule)
+
+	opts := p.cfg.GrowOptions()
+	opts.Capsule = p.capsule
+	opts.Incremental = true
+	opts.Workers = runtime.NumCPU()
+	opts.Strict = false // broken links are fixed while previewing
+
+	return build(p.src, p.out, scan, opts)
+}
+
+// watch checks the source of the garden for changes until the context is done.
+// When it changes, the garden is grown again and open pages are reloaded.
+func (p *preview) watch(ctx context.Context) {
+	last := p.snapshot()
+	ticker := time.NewTicker(pollInterval)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next := p.snapshot()
+		if next == last {
+			continue
+		}
+
+		last = next
+
+		if err := p.grow(); err != nil {
+			fmt.Fprintf(p.stderr, "gdn serve: %v\n", err)
+			continue
+		}
+
+		fmt.Fprintf(p.stdout, "%s changed, reloading\n", p.src)
+		p.reload.broadcast()
+	}
+}
+
+// snapshot returns a summary of the names, sizes and modification times of the
+// files in the source of the garden, which changes whenever they change.
+// Hidden files are skipped like they are when scanning, except for the
+// ConfigDir.  The output directories are skipped in case they are within the
+// source, and symbolic links are handled by the same policy as scanning, so
+// changes within a linked directory are noticed.
+func (p *preview) snapshot() string {
+	var b strings.Builder
+
+	out, _ := filepath.Abs(p.out)         // nolint: errcheck // best effort
+	capsule, _ := filepath.Abs(p.capsule) // nolint: errcheck // best effort
+
+	gdn.WalkSymlinks(p.src, p.symlinks, // nolint: errcheck // best effort
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+
+			hidden := strings.HasPrefix(info.Name(), ".") &&
+				info.Name() != gdn.ConfigDir && path != p.src
+			abs, _ := filepath.Abs(path) // nolint: errcheck // best effort
+			if hidden || abs == out || (p.capsule != "" && abs == capsule) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			fmt.Fprintf(&b, "%s %d %d\n",
+				path, info.Size(), info.ModTime().UnixNano())
+
+			return nil
+		})
+
+	return b.String()
+}
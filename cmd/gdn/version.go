@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+// version of gdn.  This is set when building a release with:
+//
+//     go build -ldflags "-X main.version=v1.0.0" ./cmd/gdn
+var version = "dev" // nolint: gochecknoglobals
+
+func versionCommand() command {
+	return command{
+		name:    "version",
+		summary: "Print the version of gdn.",
+		setup: func(fs *flag.FlagSet, stdout io.Writer) func([]string) error {
+			return func(args []string) error {
+				if err := noArgs(args); err != nil {
+					return err
+				}
+
+				fmt.Fprintf(stdout, "gdn %s\n", version)
+
+				return nil
+			}
+		},
+	}
+}
@@ -0,0 +1,158 @@
+package gdn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ConfigFile is the name of the site configuration file in the root of the
+// garden.
+const ConfigFile = "gdn.json"
+
+// Config is the configuration of a site, loaded from the ConfigFile.  Each
+// setting is left at its zero value when it is not in the file.
+type Config struct {
+	// Title is the title of the site.  See Site.
+	Title string `json:"title"`
+	// URL is the URL the site is published at.  See Site.
+	URL string `json:"url"`
+	// Author is the author of the site.  See Site.
+	Author string `json:"author"`
+	// Out is the directory the site is grown into.  A relative path is
+	// relative to the root of the garden.
+	Out string `json:"out"`
+	// Capsule is the directory a Gemini capsule is grown into, if any.  A
+	// relative path is relative to the root of the garden.
+	Capsule string `json:"capsule"`
+	// Ignore are patterns of files and directories to leave out of the tree,
+	// written like the lines of the IgnoreFile.  The IgnoreFile is matched
+	// after them, so it can include what they leave out.
+	Ignore []string `json:"ignore"`
+	// Extensions maps file extensions to the name of the type of those files
+	// in the Registry, such as {".txt": "Gemini"}.
+	Extensions map[string]string `json:"extensions"`
+	// Symlinks is how symbolic links in the garden are handled, such as
+	// "skip".  See SymlinkPolicy.
+	Symlinks SymlinkPolicy `json:"symlinks"`
+
+	// Drafts includes pages marked as drafts.  See ScanOptions.
+	Drafts bool `json:"drafts"`
+	// TOC shows a table of contents on every page.  See GrowOptions.
+	TOC bool `json:"toc"`
+	// Feed writes feeds of recently updated pages.  See GrowOptions.
+	Feed bool `json:"feed"`
+	// FeedSize is the number of pages listed in the feeds.  See GrowOptions.
+	FeedSize int `json:"feedSize"`
+	// Sort is the order of the entries in generated index pages, such as
+	// "modified".  See IndexSort.
+	Sort IndexSort `json:"sort"`
+	// Incremental only grows what changed since the last incremental grow.
+	// See GrowOptions.
+	Incremental bool `json:"incremental"`
+	// Strict stops growing when a page has a broken link.  See GrowOptions.
+	Strict bool `json:"strict"`
+	// Prune removes files that were not grown from the output directories.
+	// See GrowOptions.
+	Prune bool `json:"prune"`
+	// Fingerprint puts a hash of each asset's contents in its name.  See
+	// GrowOptions.
+	Fingerprint bool `json:"fingerprint"`
+}
+
+// LoadConfig loads the ConfigFile of the garden rooted at the given source
+// directory.  An empty Config is returned when the garden does not have one.
+// Settings that are not known are an error, so that mistakes in their names
+// are not missed.
+func LoadConfig(src string) (Config, error) {
+	var cfg Config
+
+	file := filepath.Join(src, ConfigFile)
+
+	b, err := ioutil.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, fmt.Errorf("could not read config: %s: %w", file, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("could not parse config: %s: %w", file, err)
+	}
+
+	if cfg.Out != "" && !filepath.IsAbs(cfg.Out) {
+		cfg.Out = filepath.Join(src, cfg.Out)
+	}
+
+	if cfg.Capsule != "" && !filepath.IsAbs(cfg.Capsule) {
+		cfg.Capsule = filepath.Join(src, cfg.Capsule)
+	}
+
+	return cfg, nil
+}
+
+// Site returns the title, URL and author of the site.
+func (c Config) Site() Site {
+	return Site{Title: c.Title, URL: c.URL, Author: c.Author}
+}
+
+// ScanOptions returns the options for scanning the garden.  The Out and
+// Capsule directories are excluded from the tree.
+func (c Config) ScanOptions() ScanOptions {
+	opts := ScanOptions{
+		Drafts:   c.Drafts,
+		Ignore:   c.Ignore,
+		Symlinks: c.Symlinks,
+	}
+
+	for _, dir := range []string{c.Out, c.Capsule} {
+		if dir != "" {
+			opts.Exclude = append(opts.Exclude, dir)
+		}
+	}
+
+	return opts
+}
+
+// GrowOptions returns the options for growing the garden.
+func (c Config) GrowOptions() GrowOptions {
+	return GrowOptions{
+		Capsule:     c.Capsule,
+		IndexSort:   c.Sort,
+		Incremental: c.Incremental,
+		Feed:        c.Feed,
+		FeedSize:    c.FeedSize,
+		TOC:         c.TOC,
+		Strict:      c.Strict,
+		Prune:       c.Prune,
+		Fingerprint: c.Fingerprint,
+		Site:        c.Site(),
+	}
+}
+
+// Register maps the Extensions to their types in the registry.  The extensions
+// are added in order, and it stops at the first type that is not registered.
+func (c Config) Register(r *Registry) error {
+	exts := make([]string, 0, len(c.Extensions))
+	for ext := range c.Extensions {
+		exts = append(exts, ext)
+	}
+
+	sort.Strings(exts)
+
+	for _, ext := range exts {
+		if err := r.AddExtension(ext, c.Extensions[ext]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package gdn_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	t.Log("+test a garden without a config has an empty config")
+
+	cfg, err := gdn.LoadConfig(tmp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(cfg, gdn.Config{}) {
+		t.Errorf("config gave: %+v, expecting an empty config", cfg)
+	}
+
+	t.Log("+test loading each setting")
+
+	writeFile(t, filepath.Join(tmp, gdn.ConfigFile), `{
+	"title": "My Garden",
+	"url": "https://example.com/",
+	"author": "Kiba",
+	"out": "public",
+	"capsule": "/srv/gemini",
+	"ignore": ["*.draft.gmi"],
+	"extensions": {".txt": "Gemini"},
+	"symlinks": "skip",
+	"drafts": true,
+	"toc": true,
+	"feed": true,
+	"feedSize": 5,
+	"sort": "modified",
+	"incremental": true,
+	"strict": true,
+	"fingerprint": true
+}`)
+
+	cfg, err = gdn.LoadConfig(tmp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := gdn.Config{
+		Title:       "My Garden",
+		URL:         "https://example.com/",
+		Author:      "Kiba",
+		Out:         filepath.Join(tmp, "public"),
+		Capsule:     "/srv/gemini",
+		Ignore:      []string{"*.draft.gmi"},
+		Extensions:  map[string]string{".txt": "Gemini"},
+		Symlinks:    gdn.SkipSymlinks,
+		Drafts:      true,
+		TOC:         true,
+		Feed:        true,
+		FeedSize:    5,
+		Sort:        gdn.SortByModified,
+		Incremental: true,
+		Strict:      true,
+		Fingerprint: true,
+	}
+
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("config gave: %+v, expecting: %+v", cfg, expected)
+	}
+
+	t.Log("-test unknown settings and values are errors")
+
+	tbls := []struct {
+		json string
+		err  error
+	}{
+		{`{"titel": "My Garden"}`, nil},
+		{`{"sort": "size"}`, gdn.ErrUnknownSort},
+		{`{"symlinks": "hard"}`, gdn.ErrUnknownSymlinks},
+		{`{"toc": "yes"}`, nil},
+	}
+
+	for _, tbl := range tbls {
+		writeFile(t, filepath.Join(tmp, gdn.ConfigFile), tbl.json)
+
+		_, err := gdn.LoadConfig(tmp)
+		if err == nil {
+			t.Errorf("%s: expected an error", tbl.json)
+		} else if tbl.err != nil && !errors.Is(err, tbl.err) {
+			t.Errorf("%s: expected %v, got: %v", tbl.json, tbl.err, err)
+		}
+	}
+}
+
+func TestConfigRegister(t *testing.T) {
+	r := gdn.NewRegistry()
+	r.Register("Gemini", gdn.RendererFunc(plainText), ".gmi")
+
+	cfg := gdn.Config{Extensions: map[string]string{
+		".txt": "Gemini",
+		".md":  "Unknown",
+	}}
+
+	if err := cfg.Register(r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if typ := r.TypeByExtension(".txt"); typ != gdn.Gemini {
+		t.Errorf("TypeByExtension(.txt) gave: %s, expecting: Gemini", typ)
+	}
+
+	cfg.Extensions = map[string]string{".org": "Org"}
+
+	if err := cfg.Register(r); !errors.Is(err, gdn.ErrUnknownType) {
+		t.Errorf("expected %v, got: %v", gdn.ErrUnknownType, err)
+	}
+}
+
+func TestGrowWithConfig(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+
+	writeFile(t, filepath.Join(src, gdn.ConfigFile), `{
+	"title": "My Garden",
+	"author": "Kiba",
+	"ignore": ["*.draft.gmi"],
+	"feed": true
+}`)
+	writeFile(t, filepath.Join(src, "index.gmi"), "# Home Page\n")
+	writeFile(t, filepath.Join(src, "notes.draft.gmi"), "# Draft\n")
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.LayoutFile),
+		"{{.Site.Title}} by {{.Site.Author}}: {{.Title}}")
+
+	cfg, err := gdn.LoadConfig(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	root := gdn.NewTree(src, dst)
+
+	if err := root.ScanWith(cfg.ScanOptions()); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	if err := root.GrowWith(cfg.GrowOptions()); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	if page := readFile(t, filepath.Join(dst, "index.html")); page !=
+		"My Garden by Kiba: Home Page" {
+		t.Errorf("layout was not given the site: %q", page)
+	}
+
+	for _, name := range []string{gdn.ConfigFile, "notes.draft.html"} {
+		if _, err := os.Stat(filepath.Join(dst, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should be left out of the site: %v", name, err)
+		}
+	}
+
+	feed := readFile(t, filepath.Join(dst, gdn.FeedFile))
+	for _, want := range []string{
+		"<title>My Garden</title>",
+		"<author>\n\t\t<name>Kiba</name>",
+	} {
+		if !strings.Contains(feed, want) {
+			t.Errorf("feed does not contain %q:\n%s", want, feed)
+		}
+	}
+}
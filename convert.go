@@ -0,0 +1,454 @@
+package gdn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+	"github.com/russross/blackfriday/v2"
+)
+
+// MarkdownToGemini converts the Markdown source to Gemini text and writes it to
+// w.  A metadata block at the top of the source is kept as it is.
+//
+// Headings deeper than level 3 become level 3 headings.  Links and images
+// within text are numbered, like footnotes, and written as link lines after
+// the paragraph, list or quote they are in.  A paragraph that is only a link
+// becomes a single link line.  Nested lists are flattened, code blocks become
+// preformatted text and tables become preformatted text with their cells
+// separated by |.  Emphasis and HTML are dropped.  Links to pages in the
+// garden are pointed at their Gemini pages, so a link to notes.md becomes a
+// link to notes.gmi.
+func MarkdownToGemini(w io.Writer, src []byte) error {
+	_, body := ParseMeta(src)
+	c := &geminiConverter{}
+
+	c.buf.Write(src[:len(src)-len(body)])
+	c.blocks(parseMarkdown(body))
+
+	if _, err := c.buf.WriteTo(w); err != nil {
+		return fmt.Errorf("error writing Gemini text: %w", err)
+	}
+
+	return nil
+}
+
+// geminiConverter writes Markdown blocks as Gemini text.
+type geminiConverter struct {
+	buf   bytes.Buffer
+	refs  []gmi.LinkLine // links waiting to be written after the block
+	count int            // number of links referenced so far
+	wrote bool           // whether a block was written yet
+}
+
+// blocks writes each child block of the node.
+func (c *geminiConverter) blocks(n *blackfriday.Node) {
+	for child := n.FirstChild; child != nil; child = child.Next {
+		c.block(child)
+	}
+}
+
+// block writes a Markdown block as Gemini text, separated from the block before
+// it by a blank line.
+func (c *geminiConverter) block(n *blackfriday.Node) {
+	switch n.Type {
+	case blackfriday.Heading:
+		level := n.Level
+		if level > gmi.MaxHeadingLevel {
+			level = gmi.MaxHeadingLevel
+		}
+
+		c.start()
+		c.line(gmi.HeadingLine{Level: level, Text: c.inline(n)})
+		c.flush()
+	case blackfriday.Paragraph:
+		c.start()
+
+		if link, ok := onlyLink(n); ok {
+			c.line(link)
+			return
+		}
+
+		c.line(gmi.TextLine{Text: c.inline(n)})
+		c.flush()
+	case blackfriday.List:
+		c.start()
+		c.list(n)
+		c.flush()
+	case blackfriday.BlockQuote:
+		c.start()
+		c.quote(n)
+		c.flush()
+	case blackfriday.CodeBlock:
+		c.start()
+		c.pre(string(n.Info), strings.TrimSuffix(string(n.Literal), "\n"))
+	case blackfriday.Table:
+		c.start()
+		c.pre("", tableText(n))
+	default:
+		// Horizontal rules and HTML have no Gemini equivalent.
+	}
+}
+
+// start separates the next block from the block before it.
+func (c *geminiConverter) start() {
+	if c.wrote {
+		c.buf.WriteByte('\n')
+	}
+
+	c.wrote = true
+}
+
+// line writes a Gemini line.
+func (c *geminiConverter) line(l gmi.Line) {
+	c.buf.WriteString(l.String())
+	c.buf.WriteByte('\n')
+}
+
+// flush writes the links referenced in the last block as link lines.
+func (c *geminiConverter) flush() {
+	for _, ref := range c.refs {
+		c.line(ref)
+	}
+
+	c.refs = nil
+}
+
+// list writes every item of the list, and of the lists nested within it, as
+// list lines.  Other blocks within the items are written after the item.
+func (c *geminiConverter) list(n *blackfriday.Node) {
+	for item := n.FirstChild; item != nil; item = item.Next {
+		for child := item.FirstChild; child != nil; child = child.Next {
+			switch child.Type {
+			case blackfriday.Paragraph:
+				c.line(gmi.ListLine{Text: c.inline(child)})
+			case blackfriday.List:
+				c.list(child)
+			default:
+				c.block(child)
+			}
+		}
+	}
+}
+
+// quote writes each paragraph of the block quote as a quote line.
+func (c *geminiConverter) quote(n *blackfriday.Node) {
+	for child := n.FirstChild; child != nil; child = child.Next {
+		switch child.Type {
+		case blackfriday.Paragraph:
+			c.line(gmi.QuoteLine{Text: c.inline(child)})
+		case blackfriday.BlockQuote:
+			c.quote(child)
+		default:
+			c.block(child)
+		}
+	}
+}
+
+// pre writes the text as preformatted text with the alt text.
+func (c *geminiConverter) pre(alt, text string) {
+	c.line(gmi.PreStartLine{Alt: alt})
+
+	for _, line := range strings.Split(text, "\n") {
+		c.line(gmi.PreBodyLine{Text: line})
+	}
+
+	c.line(gmi.PreEndLine{})
+}
+
+// inline returns the text within the node on a single line.  Each link and
+// image is replaced by its text and a reference number, and is kept to be
+// written by flush.
+func (c *geminiConverter) inline(n *blackfriday.Node) string {
+	var text strings.Builder
+
+	n.Walk(func(child *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering {
+			return blackfriday.GoToNext
+		}
+
+		switch child.Type {
+		case blackfriday.Text, blackfriday.Code:
+			text.WriteString(
+				strings.ReplaceAll(string(child.Literal), "\n", " "))
+		case blackfriday.Softbreak, blackfriday.Hardbreak:
+			text.WriteString(" ")
+		case blackfriday.Link, blackfriday.Image:
+			c.count++
+			label := nodeText(child)
+			fmt.Fprintf(&text, "%s[%d]", label, c.count)
+
+			c.refs = append(c.refs, gmi.LinkLine{
+				URL:  retargetLink(string(child.Destination), ".gmi"),
+				Text: strings.TrimSpace(fmt.Sprintf("[%d] %s", c.count, label)),
+			})
+
+			return blackfriday.SkipChildren
+		default:
+		}
+
+		return blackfriday.GoToNext
+	})
+
+	return strings.TrimSpace(text.String())
+}
+
+// onlyLink returns the link line for a paragraph that only has a link or an
+// image in it.  Empty text around the link is ignored.
+func onlyLink(n *blackfriday.Node) (gmi.LinkLine, bool) {
+	var link *blackfriday.Node
+
+	for child := n.FirstChild; child != nil; child = child.Next {
+		switch {
+		case child.Type == blackfriday.Text &&
+			len(bytes.TrimSpace(child.Literal)) == 0:
+		case link == nil && (child.Type == blackfriday.Link ||
+			child.Type == blackfriday.Image):
+			link = child
+		default:
+			return gmi.LinkLine{}, false
+		}
+	}
+
+	if link == nil {
+		return gmi.LinkLine{}, false
+	}
+
+	return gmi.LinkLine{
+		URL:  retargetLink(string(link.Destination), ".gmi"),
+		Text: nodeText(link),
+	}, true
+}
+
+// tableText returns the rows of the table with their cells separated by |.
+func tableText(n *blackfriday.Node) string {
+	var rows []string
+
+	n.Walk(func(row *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering || row.Type != blackfriday.TableRow {
+			return blackfriday.GoToNext
+		}
+
+		var cells []string
+		for cell := row.FirstChild; cell != nil; cell = cell.Next {
+			cells = append(cells, nodeText(cell))
+		}
+
+		rows = append(rows, strings.Join(cells, " | "))
+
+		return blackfriday.SkipChildren
+	})
+
+	return strings.Join(rows, "\n")
+}
+
+// GeminiToMarkdown converts the Gemini text source to Markdown and writes it to
+// w.  A metadata block at the top of the source is kept as it is.
+//
+// Each text line becomes a paragraph, with the characters that mean something
+// in Markdown escaped.  A run of link lines becomes a list of links, or a
+// single link when it is alone.  Lists, quotes and preformatted text become
+// their Markdown equivalents, and empty lines are dropped since paragraphs are
+// already separated by a blank line.  Links to pages in the garden are pointed
+// at their Markdown pages, so a link to notes.gmi becomes a link to notes.md.
+func GeminiToMarkdown(w io.Writer, src []byte) error {
+	_, body := ParseMeta(src)
+	buf := bufio.NewWriter(w)
+	s := gmi.NewScanner(bytes.NewReader(body))
+	m := &markdownConverter{w: buf}
+
+	buf.Write(src[:len(src)-len(body)])
+
+	for s.Scan() {
+		m.line(s)
+	}
+
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("error scanning Gemini text: %w", err)
+	}
+
+	m.end()
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("error writing Markdown: %w", err)
+	}
+
+	return nil
+}
+
+// markdownConverter writes Gemini lines as Markdown.
+type markdownConverter struct {
+	w     *bufio.Writer
+	open  gmi.LineType   // type of the last line written, 0 after a break
+	links []gmi.LinkLine // run of link lines waiting to be written
+	wrote bool           // whether a block was written yet
+}
+
+// line writes the line the scanner is on as Markdown.
+func (m *markdownConverter) line(s *gmi.Scanner) {
+	typ := s.Type()
+
+	if typ == gmi.Link {
+		m.links = append(m.links, gmi.LinkLine{URL: s.URL(), Text: s.Text()})
+		return
+	}
+
+	m.writeLinks()
+
+	switch typ {
+	case gmi.Head1, gmi.Head2, gmi.Head3:
+		m.start(typ)
+		fmt.Fprintf(m.w, "%s %s\n", strings.Repeat("#", int(typ-gmi.Head1)+1),
+			escapeMarkdown(s.Text(), false))
+	case gmi.Text:
+		if s.Text() == "" {
+			m.open = 0
+			return
+		}
+
+		m.start(typ)
+		fmt.Fprintf(m.w, "%s\n", escapeMarkdown(s.Text(), true))
+	case gmi.List:
+		m.start(typ)
+		fmt.Fprintf(m.w, "- %s\n", escapeMarkdown(s.Text(), false))
+	case gmi.Quote:
+		if m.open == gmi.Quote {
+			m.w.WriteString(">\n")
+		}
+
+		m.start(typ)
+		quote := strings.TrimLeftFunc(s.Text(), unicode.IsSpace)
+		fmt.Fprintf(m.w, "> %s\n", escapeMarkdown(quote, true))
+	case gmi.PreStart:
+		m.start(typ)
+		fmt.Fprintf(m.w, "```%s\n", s.Text())
+	case gmi.PreBody:
+		fmt.Fprintf(m.w, "%s\n", s.Text())
+	case gmi.PreEnd:
+		m.w.WriteString("```\n")
+		m.open = typ
+	default:
+	}
+}
+
+// start separates the next line from the block before it with a blank line,
+// unless the line continues a list or a quote.
+func (m *markdownConverter) start(typ gmi.LineType) {
+	continues := m.open == typ && (typ == gmi.List || typ == gmi.Quote)
+	if m.wrote && !continues {
+		m.w.WriteByte('\n')
+	}
+
+	m.open = typ
+	m.wrote = true
+}
+
+// writeLinks writes the run of link lines as a list of links, or as a single
+// link when there is only one.
+func (m *markdownConverter) writeLinks() {
+	if len(m.links) == 0 {
+		return
+	}
+
+	m.open = 0
+	m.start(gmi.Link)
+
+	for _, l := range m.links {
+		text := l.Text
+		if text == "" {
+			text = l.URL
+		}
+
+		link := fmt.Sprintf("[%s](%s)", escapeMarkdown(text, false),
+			markdownURL(retargetLink(l.URL, ".md")))
+
+		if len(m.links) == 1 {
+			fmt.Fprintf(m.w, "%s\n", link)
+		} else {
+			fmt.Fprintf(m.w, "- %s\n", link)
+		}
+	}
+
+	m.links = nil
+}
+
+// end writes anything still waiting at the end of the Gemini text, and ends
+// preformatted text that was never ended.
+func (m *markdownConverter) end() {
+	m.writeLinks()
+
+	if m.open == gmi.PreStart || m.open == gmi.PreBody {
+		m.w.WriteString("```\n")
+	}
+}
+
+// escapeMarkdown escapes the characters in the text that would otherwise be
+// formatted as Markdown.  When block is set, characters at the start of the
+// text that would start a heading, list or quote are escaped too.  Underscores
+// within words are left alone since they do not start emphasis.
+func escapeMarkdown(text string, block bool) string {
+	var b strings.Builder
+
+	runes := []rune(text)
+	for i, r := range runes {
+		switch r {
+		case '\\', '*', '`', '[', ']', '<':
+			b.WriteByte('\\')
+		case '_':
+			if i == 0 || i == len(runes)-1 ||
+				!isWordRune(runes[i-1]) || !isWordRune(runes[i+1]) {
+				b.WriteByte('\\')
+			}
+		}
+
+		b.WriteRune(r)
+	}
+
+	if block {
+		return escapeBlock(b.String())
+	}
+
+	return b.String()
+}
+
+// isWordRune returns whether the rune is part of a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// escapeBlock escapes the start of the text if it would otherwise start a
+// Markdown heading, list, quote or thematic break.
+func escapeBlock(text string) string {
+	if text == "" {
+		return text
+	}
+
+	switch text[0] {
+	case '#', '>', '-', '+':
+		return "\\" + text
+	}
+
+	// Ordered lists, such as 1. or 1), are escaped after the number.
+	digits := strings.TrimLeftFunc(text, unicode.IsDigit)
+	if len(digits) < len(text) &&
+		(strings.HasPrefix(digits, ".") || strings.HasPrefix(digits, ")")) {
+		n := len(text) - len(digits)
+		return text[:n] + "\\" + text[n:]
+	}
+
+	return text
+}
+
+// markdownURL returns the URL as the destination of a Markdown link, wrapped in
+// angle brackets if it has spaces or parentheses.
+func markdownURL(u string) string {
+	if strings.ContainsAny(u, " ()") {
+		return "<" + u + ">"
+	}
+
+	return u
+}
@@ -0,0 +1,153 @@
+package gdn_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+func TestMarkdownToGemini(t *testing.T) {
+	tbls := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"heading", "# Title\n\n#### Deep\n", "# Title\n\n### Deep\n"},
+		{
+			"links are referenced after the paragraph",
+			"See [the site](https://example.tld/) and ![a map](map.png).\n\n" +
+				"Then **more** [text](more.md).\n",
+			"See the site[1] and a map[2].\n" +
+				"=> https://example.tld/ [1] the site\n" +
+				"=> map.png [2] a map\n" +
+				"\n" +
+				"Then more text[3].\n" +
+				"=> more.gmi [3] text\n",
+		},
+		{"only a link", "[Notes](notes.md)\n", "=> notes.gmi Notes\n"},
+		{
+			"links to pages are retargeted",
+			"[Notes](notes.md#top) [Site](https://example.tld/a.md) " +
+				"![Cat](cat.png)\n",
+			"Notes[1] Site[2] Cat[3]\n" +
+				"=> notes.gmi#top [1] Notes\n" +
+				"=> https://example.tld/a.md [2] Site\n" +
+				"=> cat.png [3] Cat\n",
+		},
+		{
+			"nested lists are flattened",
+			"- one [link](a.md)\n  - nested\n    1. deeper\n- two\n",
+			"* one link[1]\n* nested\n* deeper\n* two\n=> a.gmi [1] link\n",
+		},
+		{
+			"quote",
+			"> one\n> line\n>\n> two\n",
+			"> one line\n> two\n",
+		},
+		{
+			"code fence",
+			"Code:\n\n```go\nfunc main() {\n\n}\n```\n",
+			"Code:\n\n```go\nfunc main() {\n\n}\n```\n",
+		},
+		{
+			"table",
+			"| a | b |\n|---|---|\n| 1 | 2 |\n",
+			"```\na | b\n1 | 2\n```\n",
+		},
+		{"rule and HTML are dropped", "A\n\n---\n\n<div>B</div>\n", "A\n"},
+		{
+			"metadata is kept",
+			"---\ntitle: Page\n---\n*Text*\n",
+			"---\ntitle: Page\n---\nText\n",
+		},
+		{"empty", "", ""},
+	}
+
+	for _, tbl := range tbls {
+		var out bytes.Buffer
+		if err := gdn.MarkdownToGemini(&out, []byte(tbl.src)); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tbl.name, err)
+		}
+
+		if out.String() != tbl.expected {
+			t.Errorf("%s: gave: %q, expecting: %q",
+				tbl.name, out.String(), tbl.expected)
+		}
+
+		diags, err := gmi.Lint(tbl.name, &out)
+		if err != nil || len(diags) != 0 {
+			t.Errorf("%s: converted Gemini text has problems: %v %v",
+				tbl.name, diags, err)
+		}
+	}
+}
+
+func TestGeminiToMarkdown(t *testing.T) {
+	tbls := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{
+			"headings and text",
+			"# Title\n## Sub\nText\n\nMore text\n",
+			"# Title\n\n## Sub\n\nText\n\nMore text\n",
+		},
+		{
+			"formatting is escaped",
+			"# A *star*\nsnake_case, _emph_ and [brackets] `code`\n" +
+				"1. not a list\n- not a list\n+ not a list\n",
+			"# A \\*star\\*\n\nsnake_case, \\_emph\\_ and \\[brackets\\] " +
+				"\\`code\\`\n\n1\\. not a list\n\n\\- not a list\n\n" +
+				"\\+ not a list\n",
+		},
+		{"single link", "=> notes.gmi Notes\n", "[Notes](notes.md)\n"},
+		{
+			"run of links",
+			"=> a.gmi A [1]\n=> b.gmi\n=> c d.gmi\n\n=> e f.gmi\n",
+			"- [A \\[1\\]](a.md)\n- [b.gmi](b.md)\n- [d.gmi](c)\n\n" +
+				"[f.gmi](e)\n",
+		},
+		{
+			"lists are grouped",
+			"* one\n* two\n\n* three\nText\n",
+			"- one\n- two\n\n- three\n\nText\n",
+		},
+		{"quotes", "> one\n>two\n", "> one\n>\n> two\n"},
+		{
+			"preformatted",
+			"```alt\n# not a heading\n```\nText\n",
+			"```alt\n# not a heading\n```\n\nText\n",
+		},
+		{"unterminated preformatted", "```\ncode", "```\ncode\n```\n"},
+		{
+			"metadata is kept",
+			"title: Page\n\n# Page\n",
+			"title: Page\n\n# Page\n",
+		},
+		{"URL with spaces", "=> <a b> x\n", "[b> x](<a)\n"},
+		{"URL with parentheses", "=> a(b).gmi x\n", "[x](<a(b).md>)\n"},
+		{
+			"links to pages are retargeted",
+			"=> notes.gmi?q=1#top Notes\n=> gemini://example.tld/a.gmi A\n" +
+				"=> cat.png Cat\n",
+			"- [Notes](notes.md?q=1#top)\n- [A](gemini://example.tld/a.gmi)\n" +
+				"- [Cat](cat.png)\n",
+		},
+	}
+
+	for _, tbl := range tbls {
+		var out strings.Builder
+		if err := gdn.GeminiToMarkdown(&out, []byte(tbl.src)); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tbl.name, err)
+		}
+
+		if out.String() != tbl.expected {
+			t.Errorf("%s: gave: %q, expecting: %q",
+				tbl.name, out.String(), tbl.expected)
+		}
+	}
+}
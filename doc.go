@@ -1,3 +1,6 @@
 // Package gdn provides tools for generating a static website from a source of
 // pages written in the Gemini text and organized in directories.
+//
+// Pages written in other formats can be grown too, by registering a Renderer
+// for their file extensions with Register.
 package gdn
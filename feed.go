@@ -0,0 +1,292 @@
+package gdn
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FeedFile is the name of the Atom feed written to the root of the site.
+const FeedFile = "atom.xml"
+
+// GemfeedFile is the name of the Gemini feed written to the root of the
+// capsule.  It follows the Gemini subscription convention, listing each page
+// with a link line whose name starts with the date it was updated.
+const GemfeedFile = "feed.gmi"
+
+// DefaultFeedSize is the number of pages listed in the feeds when the size is
+// not given.
+const DefaultFeedSize = 20
+
+// FeedEntry is a page listed in a feed.
+type FeedEntry struct {
+	Title string
+	// URL is the path of the page within the site, and CapsuleURL the path
+	// within the capsule.
+	URL, CapsuleURL string
+	// Updated is the time from the updated key of the page's metadata, or the
+	// time its source was last modified.
+	Updated time.Time
+	// Published is the time from the created or date key of the page's
+	// metadata, or the zero time if it has neither.
+	Published time.Time
+	Meta      Meta
+}
+
+// feedEntries returns the pages in the tree to list in a feed, most recently
+// updated first, keeping at most n of them.
+func (b Branch) feedEntries(g *grower, n int) ([]FeedEntry, error) {
+	var entries []FeedEntry
+
+	err := b.Walk(func(l *Leaf) error {
+		if !l.Typ.isPage() {
+			return nil
+		}
+
+		info, err := os.Stat(l.Src)
+		if err != nil {
+			return fmt.Errorf("error getting info for %s: %w", l.Src, err)
+		}
+
+		e := FeedEntry{
+			Title:      g.titles[l.Path],
+			URL:        l.URL(),
+			CapsuleURL: l.CapsuleURL(),
+			Updated:    l.Meta.Time("updated"),
+			Published:  l.Meta.Time("created"),
+			Meta:       l.Meta,
+		}
+
+		if e.Updated.IsZero() {
+			e.Updated = info.ModTime()
+		}
+
+		if e.Published.IsZero() {
+			e.Published = l.Meta.Time("date")
+		}
+
+		entries = append(entries, e)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.Updated.Equal(b.Updated) {
+			return a.Updated.After(b.Updated)
+		}
+
+		return a.URL < b.URL
+	})
+
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+
+	return entries, nil
+}
+
+// rootIndex returns the index page of the root of the tree, or nil if there is
+// none.
+func (b Branch) rootIndex() *Leaf {
+	for _, name := range indexNames {
+		for _, l := range b.Leaves {
+			if filepath.Base(l.Path) == name {
+				return l
+			}
+		}
+	}
+
+	return nil
+}
+
+// growFeeds writes the Atom feed of the site, and the Gemini feed of the
+// capsule if one is grown.  A feed is not written over a leaf of the same
+// name.
+func (b Branch) growFeeds(g *grower) error {
+	entries, err := b.feedEntries(g, g.feedSize)
+	if err != nil {
+		return err
+	}
+
+	title := g.site.Title
+	if idx := b.rootIndex(); title == "" && idx != nil {
+		title = g.titles[idx.Path]
+	} else if title == "" {
+		title = "Home"
+	}
+
+	if !b.hasLeaf(path.Join(b.URL(), FeedFile), (*Leaf).URL) {
+		if err := b.growAtom(g, title, entries); err != nil {
+			return err
+		}
+	}
+
+	if g.capsule == nil ||
+		b.hasLeaf(path.Join(b.URL(), GemfeedFile), (*Leaf).CapsuleURL) {
+		return nil
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# %s\n\n", title)
+
+	for _, e := range entries {
+		fmt.Fprintf(&buf, "=> %s %s - %s\n",
+			e.CapsuleURL, e.Updated.Format("2006-01-02"), e.Title)
+	}
+
+	dst := filepath.Join(g.capsule.dir, GemfeedFile)
+	if err := ioutil.WriteFile(dst, buf.Bytes(), LeafPerm); err != nil {
+		return fmt.Errorf("error writing %s: %w", dst, err)
+	}
+
+	return nil
+}
+
+// atomFeed is the XML of an Atom feed.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Link       atomLink       `xml:"link"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// growAtom writes the Atom feed listing the entries to the root of the site.
+func (b Branch) growAtom(g *grower, title string, entries []FeedEntry) error {
+	info, err := os.Stat(b.Src)
+	if err != nil {
+		return fmt.Errorf("error getting info for %s: %w", b.Src, err)
+	}
+
+	feed := atomFeed{
+		Title:   title,
+		ID:      g.feedID("/"),
+		Updated: info.ModTime().UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: g.siteLink("/" + FeedFile), Rel: "self"},
+			{Href: g.siteLink("/")},
+		},
+		// Atom requires an author, so the feed is by the author of the site,
+		// or of the root index page, or else by the garden itself.
+		Author: &atomAuthor{Name: g.site.Author},
+	}
+
+	if idx := b.rootIndex(); feed.Author.Name == "" && idx != nil {
+		feed.Author.Name = idx.Meta["author"]
+	}
+
+	if feed.Author.Name == "" {
+		feed.Author.Name = title
+	}
+
+	if len(entries) > 0 {
+		feed.Updated = entries[0].Updated.UTC().Format(time.RFC3339)
+	}
+
+	for _, e := range entries {
+		entry := atomEntry{
+			Title:   e.Title,
+			ID:      g.feedID(e.URL),
+			Updated: e.Updated.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: g.siteLink(e.URL)},
+		}
+
+		if !e.Published.IsZero() {
+			entry.Published = e.Published.UTC().Format(time.RFC3339)
+		}
+
+		if a := e.Meta["author"]; a != "" {
+			entry.Author = &atomAuthor{Name: a}
+		}
+
+		for _, tag := range e.Meta.List("tags") {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	out, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		return fmt.Errorf("could not encode feed: %w", err)
+	}
+
+	out = append([]byte(xml.Header), append(out, '\n')...)
+
+	dst := filepath.Join(b.Dst, FeedFile)
+	if err := ioutil.WriteFile(dst, out, LeafPerm); err != nil {
+		return fmt.Errorf("error writing %s: %w", dst, err)
+	}
+
+	return nil
+}
+
+// siteLink returns the URL path within the site resolved against the URL the
+// site is published at.  The path is returned as is when the URL is not known.
+func (g *grower) siteLink(p string) string {
+	if g.site.URL == "" {
+		return p
+	}
+
+	base, err := url.Parse(g.site.URL)
+	if err != nil {
+		return p
+	}
+
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	rel := &url.URL{Path: strings.TrimPrefix(p, "/")}
+
+	return base.ResolveReference(rel).String()
+}
+
+// feedID returns the ID of the path within the site for an Atom feed, which
+// must be an absolute URI.  It is the link to the path, or a tag URI when the
+// URL the site is published at is not known.
+func (g *grower) feedID(p string) string {
+	if g.site.URL == "" {
+		return "tag:gdn,2020:" + p
+	}
+
+	return g.siteLink(p)
+}
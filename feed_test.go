@@ -0,0 +1,115 @@
+package gdn_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestGrowFeed(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+	capsule := filepath.Join(tmp, "capsule")
+
+	writeFile(t, filepath.Join(src, "index.gmi"),
+		"---\nauthor: Kiba\nupdated: 2020-09-01\n---\n# My Garden\n")
+	writeFile(t, filepath.Join(src, "notes", "a.gmi"),
+		"---\ncreated: 2020-09-02\nupdated: 2020-09-03\ntags: [x, y]\n---\n"+
+			"# Page A\n")
+	writeFile(t, filepath.Join(src, "notes", "b.md"), "# Page B & C\n")
+	writeFile(t, filepath.Join(src, "image.png"), "png")
+
+	modified := time.Date(2020, 9, 2, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "notes", "b.md"),
+		modified, modified); err != nil {
+		t.Fatalf("could not change times: %v", err)
+	}
+
+	root := gdn.NewTree(src, dst)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	err := root.GrowWith(gdn.GrowOptions{
+		Capsule: capsule,
+		Feed:    true,
+		Site:    gdn.Site{URL: "https://example.com/garden"},
+	})
+	if err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	t.Log("+test the Atom feed lists the pages most recently updated first")
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>My Garden</title>
+	<id>https://example.com/garden/</id>
+	<updated>2020-09-03T00:00:00Z</updated>
+	<link href="https://example.com/garden/atom.xml" rel="self"></link>
+	<link href="https://example.com/garden/"></link>
+	<author>
+		<name>Kiba</name>
+	</author>
+	<entry>
+		<title>Page A</title>
+		<id>https://example.com/garden/notes/a.html</id>
+		<updated>2020-09-03T00:00:00Z</updated>
+		<published>2020-09-02T00:00:00Z</published>
+		<link href="https://example.com/garden/notes/a.html"></link>
+		<category term="x"></category>
+		<category term="y"></category>
+	</entry>
+	<entry>
+		<title>Page B &amp; C</title>
+		<id>https://example.com/garden/notes/b.html</id>
+		<updated>2020-09-02T12:00:00Z</updated>
+		<link href="https://example.com/garden/notes/b.html"></link>
+	</entry>
+	<entry>
+		<title>My Garden</title>
+		<id>https://example.com/garden/index.html</id>
+		<updated>2020-09-01T00:00:00Z</updated>
+		<link href="https://example.com/garden/index.html"></link>
+		<author>
+			<name>Kiba</name>
+		</author>
+	</entry>
+</feed>
+`
+	if feed := readFile(t, filepath.Join(dst, gdn.FeedFile)); feed != expected {
+		t.Errorf("feed gave: %q, expecting: %q", feed, expected)
+	}
+
+	t.Log("+test the Gemini feed lists the pages with dated links")
+
+	expected = "# My Garden\n\n" +
+		"=> /notes/a.gmi 2020-09-03 - Page A\n" +
+		"=> /notes/b.gmi 2020-09-02 - Page B & C\n" +
+		"=> /index.gmi 2020-09-01 - My Garden\n"
+	if feed := readFile(t, filepath.Join(capsule, gdn.GemfeedFile)); feed !=
+		expected {
+		t.Errorf("feed gave: %q, expecting: %q", feed, expected)
+	}
+
+	t.Log("+test the size of the feeds")
+
+	err = root.GrowWith(gdn.GrowOptions{Capsule: capsule, Feed: true,
+		FeedSize: 1})
+	if err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	expected = "# My Garden\n\n=> /notes/a.gmi 2020-09-03 - Page A\n"
+	if feed := readFile(t, filepath.Join(capsule, gdn.GemfeedFile)); feed !=
+		expected {
+		t.Errorf("feed gave: %q, expecting: %q", feed, expected)
+	}
+}
@@ -16,36 +16,15 @@ const (
 	Unknown FileType = iota
 	// Markdown is a markdown file type.
 	Markdown
+	// Gemini is a Gemini text file type.
+	Gemini
 )
 
 // String returns the string representation of FileType.  For example, if the
-// FileType is Markdown it will return the string "Markdown".
+// FileType is Markdown it will return the string "Markdown".  Types that are
+// not in the DefaultRegistry are "Unknown".
 func (t FileType) String() string {
-	switch t {
-	case Markdown:
-		return "Markdown"
-	case Unknown:
-		return "Unknown"
-	default:
-		return "Unknown"
-	}
-}
-
-// KnownFileTypes is a map of extensions to their FileType.
-var KnownFileTypes = map[string]FileType{ // nolint: gochecknoglobals
-	".md":       Markdown,
-	".mkd":      Markdown,
-	".markdown": Markdown,
-}
-
-// TypeByExtension will look up the type by its extension.
-func TypeByExtension(ext string) FileType {
-	typ, ok := KnownFileTypes[ext]
-	if !ok {
-		return Unknown
-	}
-
-	return typ
+	return DefaultRegistry.Name(t)
 }
 
 // ChExt takes a path and replaces any file extension that path has with the
@@ -57,6 +36,35 @@ func ChExt(path, ext string) string {
 	return p + ext
 }
 
+// realPath returns the absolute path with any symbolic links resolved, so that
+// two paths to the same directory are the same.  Parts of the path that do not
+// exist yet are kept as they are.
+func realPath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", fmt.Errorf("could not find absolute path of %s: %w", p, err)
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if os.IsNotExist(err) {
+		dir := filepath.Dir(abs)
+		if dir == abs {
+			return abs, nil
+		}
+
+		parent, err := realPath(dir)
+		if err != nil {
+			return "", err
+		}
+
+		return filepath.Join(parent, filepath.Base(abs)), nil
+	} else if err != nil {
+		return "", fmt.Errorf("could not resolve %s: %w", p, err)
+	}
+
+	return resolved, nil
+}
+
 // CopyFile will copy a file from the given source to the destination.
 func CopyFile(src, dest string) error {
 	input, err := os.Open(src)
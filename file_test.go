@@ -16,6 +16,8 @@ func TestTypeByExtension(t *testing.T) {
 		{".md", gdn.Markdown},
 		{".mkd", gdn.Markdown},
 		{".markdown", gdn.Markdown},
+		{".gmi", gdn.Gemini},
+		{".gemini", gdn.Gemini},
 		{".jpeg", gdn.Unknown},
 		{".txt", gdn.Unknown},
 		{".unknown", gdn.Unknown},
@@ -36,6 +38,7 @@ func TestFileTypeString(t *testing.T) {
 		expected string
 	}{
 		{gdn.Markdown, "Markdown"},
+		{gdn.Gemini, "Gemini"},
 		{gdn.Unknown, "Unknown"},
 		{gdn.FileType(256), "Unknown"},
 	}
@@ -0,0 +1,307 @@
+package gdn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrAssetLoop occurs when fingerprinting stylesheets that refer to each other,
+// since the fingerprint of each depends on the other.
+var ErrAssetLoop = errors.New("stylesheets refer to each other")
+
+// AssetsFile is the name of the file written to the root of the destination
+// when growing with GrowOptions.Fingerprint.  It maps the URL of each asset to
+// its fingerprinted URL.
+const AssetsFile = "assets.json"
+
+// fingerprintLen is the number of hex digits of an asset's hash that are put in
+// its name.
+const fingerprintLen = 10
+
+// assetExts are the extensions of the files that are fingerprinted: images,
+// stylesheets, scripts, fonts, audio and video.  Other files, such as
+// favicon.ico or robots.txt, are looked for by name and keep it.
+var assetExts = map[string]bool{ // nolint: gochecknoglobals
+	".css": true, ".js": true, ".mjs": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true,
+	".webp": true, ".avif": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true,
+	".mp3": true, ".ogg": true, ".mp4": true, ".webm": true,
+}
+
+// Assets maps the URLs of the assets in a site to their fingerprinted URLs,
+// such as "/style.css" to "/style.1f2e3d4c5b.css".
+type Assets map[string]string
+
+// Save writes the assets to the AssetsFile in the destination directory.
+func (a Assets) Save(dst string) error {
+	file := filepath.Join(dst, AssetsFile)
+
+	b, err := json.MarshalIndent(a, "", "\t")
+	if err != nil {
+		return fmt.Errorf("could not encode assets: %w", err)
+	}
+
+	if err := ioutil.WriteFile(file, b, LeafPerm); err != nil {
+		return fmt.Errorf("could not write assets: %s: %w", file, err)
+	}
+
+	return nil
+}
+
+// htmlRef matches the href and src attributes in HTML.  The second group is
+// the quoted reference they hold.
+var htmlRef = regexp.MustCompile( // nolint: gochecknoglobals
+	`(\s(?:href|src)=)("[^"]*"|'[^']*')()`)
+
+// cssRefs match the url() and @import references in CSS.  The second group is
+// the reference they hold, which may be quoted.
+var cssRefs = []*regexp.Regexp{ // nolint: gochecknoglobals
+	regexp.MustCompile(`(url\(\s*)("[^"]*"|'[^']*'|[^'"\s)]*)(\s*\))`),
+	regexp.MustCompile(`(@import\s+)("[^"]*"|'[^']*')()`),
+}
+
+// replaceRefs replaces each reference the expression matches in the source
+// with what fn returns for it, keeping any quotes around it.  The second group
+// of the expression is the reference, and the first and third are kept.
+func replaceRefs(re *regexp.Regexp, src []byte,
+	fn func(ref string) string) []byte {
+	return re.ReplaceAllFunc(src, func(match []byte) []byte {
+		m := re.FindSubmatch(match)
+		ref, quote := string(m[2]), ""
+
+		if len(ref) >= 2 && (ref[0] == '"' || ref[0] == '\'') {
+			quote, ref = ref[:1], ref[1:len(ref)-1]
+		}
+
+		return []byte(string(m[1]) + quote + fn(ref) + quote + string(m[3]))
+	})
+}
+
+// rewrite points the href and src attributes in the HTML of the page at the
+// given URL path to the fingerprinted URLs of the assets they refer to.
+// Relative references stay relative, and query strings and fragments are
+// kept.
+func (a Assets) rewrite(from string, html []byte) []byte {
+	if len(a) == 0 {
+		return html
+	}
+
+	return replaceRefs(htmlRef, html, func(ref string) string {
+		return a.rewriteRef(from, ref)
+	})
+}
+
+// rewriteCSS points the url() and @import references in the stylesheet at the
+// given URL path to the fingerprinted URLs of the assets they refer to, like
+// rewrite does for HTML.
+func (a Assets) rewriteCSS(from string, css []byte) []byte {
+	for _, re := range cssRefs {
+		css = replaceRefs(re, css, func(ref string) string {
+			return a.rewriteRef(from, ref)
+		})
+	}
+
+	return css
+}
+
+// rewriteRef returns the reference found in the page at the given URL path,
+// pointed at the fingerprinted URL if it refers to an asset.
+func (a Assets) rewriteRef(from, ref string) string {
+	target, ok := resolveLink(from, ref)
+	if !ok {
+		return ref
+	}
+
+	fingerprinted, ok := a[target]
+	if !ok {
+		return ref
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, path.Base(u.Path)) +
+		path.Base(fingerprinted)
+
+	return u.String()
+}
+
+// fingerprint sets the Fingerprint of every asset in the tree to the hash of
+// its contents and returns the Assets, or clears them when on is false.
+// Pages and symbolic links that are copied are never fingerprinted.  The
+// references in stylesheets are rewritten before they are hashed, so the
+// assets they refer to are fingerprinted first.
+func (b Branch) fingerprint(on bool) (Assets, error) {
+	f := &fingerprinter{
+		assets:   make(Assets),
+		leaves:   make(map[string]*Leaf),
+		visiting: make(map[string]bool),
+	}
+
+	err := b.Walk(func(l *Leaf) error {
+		l.Fingerprint = ""
+
+		if on && !l.Typ.isPage() && !l.Symlink &&
+			assetExts[strings.ToLower(filepath.Ext(l.Src))] {
+			f.leaves[l.URL()] = l
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0, len(f.leaves))
+	for u := range f.leaves {
+		urls = append(urls, u)
+	}
+
+	sort.Strings(urls)
+
+	for _, u := range urls {
+		if err := f.hash(u); err != nil {
+			return nil, err
+		}
+	}
+
+	return f.assets, nil
+}
+
+// fingerprinter holds what is needed while fingerprinting the assets of a
+// tree.
+type fingerprinter struct {
+	assets   Assets           // assets fingerprinted so far
+	leaves   map[string]*Leaf // assets to fingerprint, by their URL
+	visiting map[string]bool  // stylesheets whose references are hashed
+}
+
+// hash fingerprints the asset at the URL, after the assets a stylesheet refers
+// to.
+func (f *fingerprinter) hash(u string) error {
+	if _, ok := f.assets[u]; ok {
+		return nil
+	}
+
+	if f.visiting[u] {
+		return fmt.Errorf("could not fingerprint %s: %w", u, ErrAssetLoop)
+	}
+
+	l := f.leaves[u]
+
+	src, err := ioutil.ReadFile(l.Src)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", l.Src, err)
+	}
+
+	if isStylesheet(l.Src) {
+		f.visiting[u] = true
+
+		for _, dep := range f.deps(u, src) {
+			if err := f.hash(dep); err != nil {
+				return err
+			}
+		}
+
+		delete(f.visiting, u)
+
+		src = f.assets.rewriteCSS(u, src)
+	}
+
+	sum := sha256.Sum256(src)
+	l.Fingerprint = hex.EncodeToString(sum[:])[:fingerprintLen]
+	f.assets[u] = l.URL()
+
+	return nil
+}
+
+// deps returns the URLs of the assets the stylesheet at the URL refers to.
+func (f *fingerprinter) deps(u string, css []byte) []string {
+	var deps []string
+
+	for _, re := range cssRefs {
+		replaceRefs(re, css, func(ref string) string {
+			target, ok := resolveLink(u, ref)
+			if ok && f.leaves[target] != nil {
+				deps = append(deps, target)
+			}
+
+			return ref
+		})
+	}
+
+	return deps
+}
+
+// isStylesheet returns whether the file is a CSS stylesheet.
+func isStylesheet(p string) bool {
+	return strings.EqualFold(filepath.Ext(p), ".css")
+}
+
+// writeStylesheet writes the stylesheet of the leaf with its references to
+// assets pointed at their fingerprinted URLs, which is what its Fingerprint is
+// the hash of.
+func (l Leaf) writeStylesheet(g *grower) error {
+	src, err := ioutil.ReadFile(l.Src)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", l.Src, err)
+	}
+
+	css := g.assets.rewriteCSS(filepath.ToSlash(l.Path), src)
+
+	if err := ioutil.WriteFile(l.Dst(), css, LeafPerm); err != nil {
+		return fmt.Errorf("error writing %s: %w", l.Dst(), err)
+	}
+
+	return nil
+}
+
+// fingerprintName returns the file name with the hash put before its
+// extension, such as style.<hash>.css.
+func fingerprintName(name, hash string) string {
+	if hash == "" {
+		return name
+	}
+
+	return ChExt(name, "."+hash+filepath.Ext(name))
+}
+
+// fingerprinted is a layout that points the references to assets in the pages
+// it writes at their fingerprinted URLs.
+type fingerprinted struct {
+	layout executor
+	assets Assets
+}
+
+// Execute applies the layout to the page, then rewrites the references to
+// assets in the page relative to its path.
+func (f fingerprinted) Execute(w io.Writer, data interface{}) error {
+	var buf bytes.Buffer
+	if err := f.layout.Execute(&buf, data); err != nil {
+		return err // nolint: wrapcheck // wrapped by writePage
+	}
+
+	from := "/"
+	if page, ok := data.(Page); ok {
+		from = page.Path
+	}
+
+	_, err := w.Write(f.assets.rewrite(from, buf.Bytes()))
+
+	return err // nolint: wrapcheck // wrapped by writePage
+}
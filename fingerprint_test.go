@@ -0,0 +1,197 @@
+package gdn_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+// hashOf returns the fingerprint gdn gives to a file with the contents.
+func hashOf(contents string) string {
+	sum := sha256.Sum256([]byte(contents))
+
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+func TestGrowFingerprint(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+
+	writeFile(t, filepath.Join(src, "index.gmi"),
+		"# Home\n=> img/cat.png A cat\n=> robots.txt Robots\n")
+	writeFile(t, filepath.Join(src, "notes", "page.md"),
+		"# Page\n\n![A cat](../img/cat.png?v=1#top)\n")
+	writeFile(t, filepath.Join(src, "img", "cat.png"), "meow")
+	writeFile(t, filepath.Join(src, "style.css"), "body {}")
+	writeFile(t, filepath.Join(src, "robots.txt"), "User-agent: *")
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.LayoutFile),
+		`<link href='/style.css' rel="stylesheet">{{.Body}}`)
+
+	opts := gdn.GrowOptions{Fingerprint: true, Incremental: true}
+	cat := "cat." + hashOf("meow") + ".png"
+	style := "style." + hashOf("body {}") + ".css"
+
+	grow := func() {
+		t.Helper()
+
+		root := gdn.NewTree(src, dst)
+
+		if err := root.Scan(); err != nil {
+			t.Fatalf("scan encountered an unexpected error: %v", err)
+		}
+
+		if err := root.GrowWith(opts); err != nil {
+			t.Fatalf("grow encountered an unexpected error: %v", err)
+		}
+	}
+
+	grow()
+
+	for _, name := range []string{
+		filepath.Join("img", cat), style, "robots.txt", gdn.AssetsFile,
+	} {
+		if !pathIsRegularFile(t, filepath.Join(dst, name)) {
+			t.Errorf("%s was not grown", name)
+		}
+	}
+
+	for _, name := range []string{
+		filepath.Join("img", "cat.png"), "style.css",
+	} {
+		if _, err := os.Stat(filepath.Join(dst, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should only be grown fingerprinted: %v", name, err)
+		}
+	}
+
+	tbls := []struct {
+		page     string
+		expected []string
+	}{
+		{
+			"index.html",
+			[]string{
+				`<link href='/` + style + `'`,
+				`href="img/` + cat + `"`,
+				`href="robots.txt"`,
+			},
+		},
+		{
+			filepath.Join("notes", "page.html"),
+			[]string{
+				`<link href='/` + style + `'`,
+				`src="../img/` + cat + `?v=1#top"`,
+			},
+		},
+	}
+
+	for _, tbl := range tbls {
+		page := readFile(t, filepath.Join(dst, tbl.page))
+
+		for _, want := range tbl.expected {
+			if !strings.Contains(page, want) {
+				t.Errorf("%s does not contain %s:\n%s", tbl.page, want, page)
+			}
+		}
+	}
+
+	var assets gdn.Assets
+
+	file := readFile(t, filepath.Join(dst, gdn.AssetsFile))
+	if err := json.Unmarshal([]byte(file), &assets); err != nil {
+		t.Fatalf("could not parse %s: %v", gdn.AssetsFile, err)
+	}
+
+	expected := gdn.Assets{
+		"/img/cat.png": "/img/" + cat,
+		"/style.css":   "/" + style,
+	}
+	if !reflect.DeepEqual(assets, expected) {
+		t.Errorf("%s gave: %v, expecting: %v", gdn.AssetsFile, assets, expected)
+	}
+
+	t.Log("+test a changed asset gets a new name in the pages that use it")
+
+	writeFile(t, filepath.Join(src, "img", "cat.png"), "purr")
+	grow()
+
+	updated := "cat." + hashOf("purr") + ".png"
+
+	if page := readFile(t, filepath.Join(dst, "index.html")); !strings.Contains(
+		page, `href="img/`+updated+`"`) {
+		t.Errorf("index.html does not link to %s:\n%s", updated, page)
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "img", cat)); !os.IsNotExist(err) {
+		t.Errorf("%s should be removed once it changed: %v", cat, err)
+	}
+}
+
+func TestGrowFingerprintStylesheet(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+
+	writeFile(t, filepath.Join(src, "index.gmi"), "# Home\n")
+	writeFile(t, filepath.Join(src, "css", "site.css"),
+		"@import 'base.css';\n"+
+			"@font-face { src: url(font.woff2) }\n"+
+			"body { background: url( \"../img/bg.png\" ) }\n"+
+			"a { background: url(data:image/png;base64,AA==) }\n")
+	writeFile(t, filepath.Join(src, "css", "base.css"), "p {}")
+	writeFile(t, filepath.Join(src, "css", "font.woff2"), "font")
+	writeFile(t, filepath.Join(src, "img", "bg.png"), "bg")
+
+	root := gdn.NewTree(src, dst)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	if err := root.GrowWith(gdn.GrowOptions{Fingerprint: true}); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	t.Log("+test references in stylesheets point at fingerprinted assets")
+
+	css := "@import 'base." + hashOf("p {}") + ".css';\n" +
+		"@font-face { src: url(font." + hashOf("font") + ".woff2) }\n" +
+		"body { background: url( \"../img/bg." + hashOf("bg") + ".png\" ) }\n" +
+		"a { background: url(data:image/png;base64,AA==) }\n"
+	name := filepath.Join(dst, "css", "site."+hashOf(css)+".css")
+
+	if !pathIsRegularFile(t, name) {
+		t.Fatalf("stylesheet was not named after its rewritten contents")
+	}
+
+	if file := readFile(t, name); file != css {
+		t.Errorf("stylesheet gave: %q, expecting: %q", file, css)
+	}
+
+	t.Log("+test stylesheets that refer to each other are an error")
+
+	writeFile(t, filepath.Join(src, "css", "base.css"), "@import 'site.css';")
+
+	root = gdn.NewTree(src, dst)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	err := root.GrowWith(gdn.GrowOptions{Fingerprint: true})
+	if !errors.Is(err, gdn.ErrAssetLoop) {
+		t.Errorf("grow gave: %v, expecting: %v", err, gdn.ErrAssetLoop)
+	}
+}
@@ -1,14 +1,15 @@
 package gdn
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"html/template"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
-
-	"github.com/russross/blackfriday/v2"
+	"sync"
 )
 
 var (
@@ -20,6 +21,9 @@ var (
 	ErrNotScanned = errors.New("need to scan tree before growing it")
 	// ErrEmptyTree occurs when Scan results in an empty tree.
 	ErrEmptyTree = errors.New("scan resulted in an empty tree")
+	// ErrSymlinkLoop occurs when Scan finds a directory within itself, such as
+	// through a symbolic link to a directory above it.
+	ErrSymlinkLoop = errors.New("directory is within itself")
 )
 
 // Branch represents a directory tree used to generate the pages.
@@ -45,8 +49,33 @@ func NewTree(inputPath, outputPath string) Branch {
 
 // Scan will scan the input path for items to generate the site and build the
 // tree.  Directories are added as Branches. Files are added as Leaves.
-// Hidden files and directories are ignored.
+// Hidden files and directories are ignored, as are the ConfigFile, those
+// matching the IgnoreFile in the root of the garden and pages marked as
+// drafts.  The destination is left out too, so a site grown within its source
+// is not scanned as part of it.  See ScanWith.
 func (b *Branch) Scan() error {
+	return b.ScanWith(ScanOptions{})
+}
+
+// ScanOptions are options for scanning a tree.
+type ScanOptions struct {
+	// Drafts includes the pages marked as drafts in the tree.  A page is a
+	// draft when its metadata has draft or private set to true.
+	Drafts bool
+	// Ignore are patterns of files and directories to leave out of the tree,
+	// matched before the patterns of the IgnoreFile.  See Ignore.
+	Ignore []string
+	// Exclude are directories to leave out of the tree, such as where the site
+	// or a capsule is grown.  Empty paths are skipped.  The destination of the
+	// tree is always left out.
+	Exclude []string
+	// Symlinks is how symbolic links are handled.  They are followed by
+	// default.  See SymlinkPolicy.
+	Symlinks SymlinkPolicy
+}
+
+// ScanWith scans the input path with the given options.  See Scan.
+func (b *Branch) ScanWith(opts ScanOptions) error {
 	if b.Src == "" {
 		return ErrSrcNotSet
 	}
@@ -55,6 +84,67 @@ func (b *Branch) Scan() error {
 		return ErrDstNotSet
 	}
 
+	patterns := append([]string{"/" + ConfigFile}, opts.Ignore...)
+
+	ig, err := ParseIgnore(strings.NewReader(strings.Join(patterns, "\n")))
+	if err != nil {
+		return err
+	}
+
+	file, err := LoadIgnore(b.Src)
+	if err != nil {
+		return err
+	}
+
+	ig.patterns = append(ig.patterns, file.patterns...)
+
+	s := &scanner{
+		opts:      opts,
+		ignore:    ig,
+		exclude:   make(map[string]bool),
+		ancestors: make(map[fileID]bool),
+	}
+
+	for _, dir := range append([]string{b.Dst}, opts.Exclude...) {
+		if dir == "" {
+			continue
+		}
+
+		resolved, err := realPath(dir)
+		if err != nil {
+			return err
+		}
+
+		s.exclude[resolved] = true
+	}
+
+	return b.scan(s)
+}
+
+// scanner holds what is needed while scanning the directories of a tree.
+type scanner struct {
+	opts      ScanOptions
+	ignore    *Ignore
+	exclude   map[string]bool // real paths of the directories left out
+	ancestors map[fileID]bool // the directories being scanned
+}
+
+// scan builds the tree of the branch, leaving out what the Ignore matches and
+// the excluded directories.  Symbolic links are handled by the SymlinkPolicy.
+func (b *Branch) scan(s *scanner) error {
+	id, err := statID(b.Src)
+	if err != nil {
+		return err
+	}
+
+	if s.ancestors[id] {
+		return fmt.Errorf("could not scan directory: %s: %w", b.Src,
+			ErrSymlinkLoop)
+	}
+
+	s.ancestors[id] = true
+	defer delete(s.ancestors, id)
+
 	files, err := ioutil.ReadDir(b.Src)
 	if err != nil {
 		return fmt.Errorf("could not scan directory: %s: %w", b.Src, err)
@@ -66,6 +156,20 @@ func (b *Branch) Scan() error {
 			continue
 		}
 
+		var link bool
+
+		f, link, err = s.symlink(filepath.Join(b.Src, f.Name()), f)
+		if err != nil {
+			return err
+		} else if f == nil {
+			continue
+		}
+
+		if s.ignore.Match(filepath.ToSlash(filepath.Join(b.Path, f.Name())),
+			f.IsDir()) {
+			continue
+		}
+
 		if f.IsDir() {
 			branch := &Branch{
 				Src:  filepath.Join(b.Src, f.Name()),
@@ -73,7 +177,13 @@ func (b *Branch) Scan() error {
 				Path: filepath.Join(b.Path, f.Name()),
 			}
 
-			err := branch.Scan()
+			if excluded, err := s.excluded(branch.Src); err != nil {
+				return err
+			} else if excluded {
+				continue
+			}
+
+			err := branch.scan(s)
 			if errors.Is(err, ErrEmptyTree) {
 				// Skip empty branches
 				continue
@@ -83,12 +193,28 @@ func (b *Branch) Scan() error {
 
 			b.Branches = append(b.Branches, branch)
 		} else {
-			b.Leaves = append(b.Leaves, &Leaf{
-				Src:    filepath.Join(b.Src, f.Name()),
-				DstDir: b.Dst,
-				Path:   filepath.Join(b.Path, f.Name()),
-				Typ:    TypeByExtension(filepath.Ext(f.Name())),
-			})
+			leaf := &Leaf{
+				Src:     filepath.Join(b.Src, f.Name()),
+				DstDir:  b.Dst,
+				Path:    filepath.Join(b.Path, f.Name()),
+				Typ:     TypeByExtension(filepath.Ext(f.Name())),
+				Symlink: link,
+			}
+
+			if link {
+				leaf.Typ = Unknown
+			}
+
+			if !s.opts.Drafts {
+				draft, err := leaf.isDraft()
+				if err != nil {
+					return err
+				} else if draft {
+					continue
+				}
+			}
+
+			b.Leaves = append(b.Leaves, leaf)
 		}
 	}
 
@@ -99,11 +225,119 @@ func (b *Branch) Scan() error {
 	return nil
 }
 
+// symlink returns the info of the file at the path as the SymlinkPolicy
+// handles it, and whether it is kept as a symbolic link.  Links that are
+// followed are given the info of their target, and links that are skipped are
+// given nil.  Other files are returned as they are.
+func (s *scanner) symlink(p string, info os.FileInfo) (os.FileInfo, bool,
+	error) {
+	if info.Mode()&os.ModeSymlink == 0 {
+		return info, false, nil
+	}
+
+	switch s.opts.Symlinks {
+	case SkipSymlinks:
+		return nil, false, nil
+	case CopySymlinks:
+		return info, true, nil
+	case FollowSymlinks:
+	}
+
+	target, err := os.Stat(p)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not follow link: %s: %w", p, err)
+	}
+
+	return target, false, nil
+}
+
+// excluded returns whether the directory is one of the excluded directories.
+func (s *scanner) excluded(dir string) (bool, error) {
+	resolved, err := realPath(dir)
+	if err != nil {
+		return false, err
+	}
+
+	return s.exclude[resolved], nil
+}
+
 // BranchPerm sets the permission for the directories produced when growing.
 const BranchPerm os.FileMode = 0750
 
-// Grow generates the site from the branch.
+// Grow generates the site from the branch.  Pages are wrapped in the layout
+// found in the ConfigDir of the branch's source, or the DefaultLayout if there
+// is none.
 func (b Branch) Grow() error {
+	return b.GrowContext(context.Background(), GrowOptions{})
+}
+
+// GrowOptions are options for growing a tree.
+type GrowOptions struct {
+	// Capsule, if set, is the directory to grow a Gemini capsule into
+	// alongside the HTML site.  Gemini pages in the capsule are wrapped in
+	// the Gemini layout found in the ConfigDir, or the DefaultGeminiLayout if
+	// there is none, to give them the same navigation and backlinks as the
+	// HTML pages.
+	Capsule string
+	// IndexSort is the order of the entries in the index pages generated for
+	// directories without an index page.  The index pages are generated from
+	// the index template found in the ConfigDir, or the DefaultIndex if there
+	// is none.
+	IndexSort IndexSort
+	// Incremental skips growing the leaves that have not changed since the
+	// last incremental grow into the same destination, and removes what was
+	// grown from leaves that are no longer in the tree.  What was grown is
+	// recorded in the ManifestFile in the root of the destination.  A leaf is
+	// grown again when its source, the pages linking to it, the files in the
+	// ConfigDir, or these options change.
+	Incremental bool
+	// Workers is the number of leaves grown at the same time.  Leaves are
+	// grown one at a time when it is less than 2.
+	Workers int
+	// Feed writes an Atom feed of the most recently updated pages to the
+	// FeedFile in the root of the site, and a Gemini feed to the GemfeedFile
+	// in the root of the capsule if one is grown.
+	Feed bool
+	// FeedSize is the number of pages listed in the feeds.  The
+	// DefaultFeedSize is used when it is less than 1.
+	FeedSize int
+	// TOC shows a table of contents on every page, unless the page turns it
+	// off with "toc: false" in its metadata.  Pages can turn it on for
+	// themselves with "toc: true".
+	TOC bool
+	// Strict stops growing before anything is grown when a page has a broken
+	// link, returning a *BrokenLinksError listing them.  See CheckLinks.
+	Strict bool
+	// Prune removes the files in the destination and the capsule that were not
+	// grown from the tree, such as pages of files that were since deleted,
+	// once everything is grown.  Growing fails before anything is grown if
+	// pruning is not safe.  See Prune.
+	Prune bool
+	// Site is the title, URL and author of the site, which are given to the
+	// layouts.  The links in the Atom feed are resolved against its URL.
+	Site Site
+	// Fingerprint writes assets such as images, stylesheets and scripts as
+	// name.<hash>.ext, where the hash is of their contents, so browsers do not
+	// keep stale copies of them after they change.  The references to them in
+	// the HTML pages are rewritten, and the AssetsFile lists the fingerprinted
+	// URL of each asset.  The capsule keeps the names of the assets.
+	Fingerprint bool
+}
+
+// GrowWith generates the site from the branch with the given options.  See
+// Grow.
+func (b Branch) GrowWith(opts GrowOptions) error {
+	return b.GrowContext(context.Background(), opts)
+}
+
+// GrowContext generates the site from the branch with the given options.  See
+// Grow.
+//
+// Growing does not stop at the first leaf that fails to grow.  Every leaf is
+// grown, and the errors are returned together as Errors in the order of the
+// leaves in the tree.  When the context is done, no more leaves are grown, and
+// the context's error is returned along with any other errors.
+func (b Branch) GrowContext(ctx context.Context, opts GrowOptions) error {
 	if b.Src == "" {
 		return ErrSrcNotSet
 	}
@@ -116,32 +350,191 @@ func (b Branch) Grow() error {
 		return ErrNotScanned
 	}
 
-	if err := os.MkdirAll(b.Dst, BranchPerm); err != nil {
-		return fmt.Errorf("error making directory: %s: %w", b.Dst, err)
+	if opts.Prune {
+		if err := b.checkPrune(opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.Strict {
+		broken, err := b.CheckLinks(ctx, CheckLinksOptions{})
+		if err != nil {
+			return err
+		}
+
+		if len(broken) > 0 {
+			return &BrokenLinksError{Links: broken}
+		}
+	}
+
+	assets, err := b.fingerprint(opts.Fingerprint)
+	if err != nil {
+		return err
+	}
+
+	g, err := survey(b)
+	if err != nil {
+		return err
+	}
+
+	if g.layout, err = LoadLayout(b.Src); err != nil {
+		return err
+	}
+
+	if opts.Fingerprint {
+		g.assets = assets
+		g.layout = fingerprinted{layout: g.layout, assets: assets}
+	}
+
+	if g.index, err = LoadIndex(b.Src); err != nil {
+		return err
+	}
+
+	g.dst = b.Dst
+	g.sort = opts.IndexSort
+	g.ctx = ctx
+	g.workers = opts.Workers
+	g.site = opts.Site
+	g.toc = opts.TOC
+
+	if opts.Feed {
+		g.feedSize = opts.FeedSize
+		if g.feedSize < 1 {
+			g.feedSize = DefaultFeedSize
+		}
 	}
 
-	for _, leaf := range b.Leaves {
-		if err := leaf.Grow(); err != nil {
+	if opts.Capsule != "" {
+		if g.capsule, err = loadCapsule(b.Src, opts.Capsule); err != nil {
 			return err
 		}
 	}
 
-	for _, branch := range b.Branches {
-		if err := branch.Grow(); err != nil {
+	if !opts.Incremental {
+		err = b.grow(g)
+	} else {
+		err = b.growIncremental(g, opts, assets)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if opts.Fingerprint {
+		if err := assets.Save(b.Dst); err != nil {
 			return err
 		}
 	}
 
+	if !opts.Prune {
+		return nil
+	}
+
+	_, err = b.Prune(opts)
+
+	return err
+}
+
+// growIncremental grows the branch using the manifest of the last incremental
+// grow to skip the leaves that have not changed.  Every page is grown again
+// when the fingerprinted assets change.
+func (b Branch) growIncremental(g *grower, opts GrowOptions,
+	assets Assets) error {
+	var err error
+
+	if g.manifest, err = LoadManifest(b.Dst); err != nil {
+		return err
+	}
+
+	g.next = newManifest()
+
+	if g.next.Deps, err = configHash(b.Src, opts, assets); err != nil {
+		return err
+	}
+
+	last := g.manifest
+
+	if g.next.Deps != last.Deps {
+		// Everything depends on what changed, so nothing is fresh.  What was
+		// grown last time is still removed when it is not grown again, such as
+		// assets with a new fingerprint.
+		g.manifest = newManifest()
+	}
+
+	if err := b.grow(g); err != nil {
+		return err
+	}
+
+	dirs := b.pruneDirs(opts)
+
+	for _, out := range last.stale(g.next, b.Dst, opts.Capsule) {
+		if !withinAny(dirs, out) {
+			return fmt.Errorf("%w: %s is outside of %s",
+				ErrUnsafePrune, out, strings.Join(dirs, " and "))
+		}
+
+		if err := removeOutput(out); err != nil {
+			return err
+		}
+	}
+
+	return g.next.Save(b.Dst)
+}
+
+// removeOutput removes a file grown before, or a directory once it is empty.  A
+// directory that still holds files that were not grown from the garden is
+// kept.
+func removeOutput(p string) error {
+	if files, err := ioutil.ReadDir(p); err == nil && len(files) > 0 {
+		return nil
+	}
+
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing %s: %w", p, err)
+	}
+
 	return nil
 }
 
-// Leaf represnts a file.  If it is a Markdown file it will be generated into a
-// page.
+// grower holds what is shared by every branch and leaf while growing a tree.
+type grower struct {
+	dst    string             // root of the destination
+	layout executor           // layout wrapping each page
+	titles map[string]string  // titles of the pages, by leaf Path
+	graph  *LinkGraph         // links between the leaves
+	index  *template.Template // index of directories without an index page
+	sort   IndexSort          // order of the entries in an index
+	// capsule, if not nil, is the Gemini capsule to grow alongside the site.
+	capsule *capsule
+	// manifest, if not nil, is the manifest of the last incremental grow, and
+	// next is the manifest of this grow.
+	manifest, next *Manifest
+	mu             sync.Mutex // guards next while leaves grow concurrently
+
+	ctx     context.Context // stops growing when done
+	workers int             // number of leaves grown at the same time
+
+	feedSize int    // pages listed in the feeds, which are not grown if 0
+	site     Site   // title, URL and author of the site
+	assets   Assets // fingerprinted URLs of the assets, if fingerprinting
+	toc      bool   // whether pages show a table of contents by default
+}
+
+// Leaf represnts a file.  If its type has a Renderer in the DefaultRegistry,
+// such as Markdown and Gemini files, it will be generated into a page.
 type Leaf struct {
 	Src    string
 	DstDir string
 	Path   string
 	Typ    FileType
+	Meta   Meta // metadata of a page, read when the tree is grown
+	// Symlink is whether the leaf is a symbolic link that is copied as a link
+	// rather than grown.  See CopySymlinks.
+	Symlink bool
+	// Fingerprint is the hash of an asset's contents that is put in the name
+	// of its destination.  It is set when the tree is grown with
+	// GrowOptions.Fingerprint.
+	Fingerprint string
 }
 
 // LeafPerm is the permission to set for the generated file the leaf produces.
@@ -149,18 +542,51 @@ const LeafPerm os.FileMode = 0640
 
 // Dst is the destination file path for the leaf when Grow is executed.
 func (l Leaf) Dst() string {
-	switch l.Typ {
-	case Markdown:
+	if l.Typ.isPage() {
 		return ChExt(filepath.Join(l.DstDir, filepath.Base(l.Src)), ".html")
-	case Unknown:
-		return filepath.Join(l.DstDir, filepath.Base(l.Src))
-	default:
-		return filepath.Join(l.DstDir, filepath.Base(l.Src))
 	}
+
+	return filepath.Join(l.DstDir,
+		fingerprintName(filepath.Base(l.Src), l.Fingerprint))
 }
 
-// Grow will generate a page for the leaf.
+// URL is the path of the leaf's destination within the generated site.  It is
+// always slash separated (e.g. /notes/page.html).
+func (l Leaf) URL() string {
+	if l.Typ.isPage() {
+		return filepath.ToSlash(ChExt(l.Path, ".html"))
+	}
+
+	return filepath.ToSlash(filepath.Join(filepath.Dir(l.Path),
+		fingerprintName(filepath.Base(l.Path), l.Fingerprint)))
+}
+
+// isDraft returns whether the leaf is a page marked as a draft or as private in
+// its metadata.
+func (l Leaf) isDraft() (bool, error) {
+	if !l.Typ.isPage() {
+		return false, nil
+	}
+
+	src, err := ioutil.ReadFile(l.Src)
+	if err != nil {
+		return false, fmt.Errorf("error reading %s: %w", l.Src, err)
+	}
+
+	meta, _ := ParseMeta(src)
+
+	return meta.Bool("draft") || meta.Bool("private"), nil
+}
+
+// Grow will generate a page for the leaf.  Pages are wrapped in the
+// DefaultLayout.  Since the leaf is grown on its own, the page has no
+// backlinks; use Branch.Grow to include them.
 func (l Leaf) Grow() error {
+	return l.grow(&grower{layout: defaultLayout, ctx: context.Background()})
+}
+
+// grow will generate a page for the leaf using the shared grower.
+func (l Leaf) grow(g *grower) error {
 	if l.Src == "" {
 		return ErrSrcNotSet
 	}
@@ -169,30 +595,80 @@ func (l Leaf) Grow() error {
 		return ErrDstNotSet
 	}
 
-	switch l.Typ {
-	case Markdown:
-		m, readErr := ioutil.ReadFile(l.Src)
-		if readErr != nil {
-			return fmt.Errorf("error reading %s: %w", l.Src, readErr)
-		}
+	if g.next == nil {
+		return l.write(g)
+	}
 
-		writeErr := ioutil.WriteFile(l.Dst(), blackfriday.Run(m), LeafPerm)
-		if writeErr != nil {
-			return fmt.Errorf("error writing %s: %w", l.Dst(), readErr)
+	e, err := l.entry(g)
+	if err != nil {
+		return err
+	}
+
+	if !g.manifest.fresh(l.Path, e, g.dst, g.capsuleDir()) {
+		if err := l.write(g); err != nil {
+			return err
 		}
+	}
+
+	g.mu.Lock()
+	g.next.Leaves[l.Path] = e
+	g.mu.Unlock()
 
-	case Unknown:
-		err := CopyFile(l.Src, l.Dst())
+	return nil
+}
+
+// write writes the files grown from the leaf.  Pages are rendered with the
+// Renderer of their type and wrapped in the layout, and other files are copied.
+func (l Leaf) write(g *grower) error {
+	if l.Typ.isPage() {
+		src, err := ioutil.ReadFile(l.Src)
 		if err != nil {
-			return fmt.Errorf("error copying %s to %s: %w", l.Src, l.Dst(), err)
+			return fmt.Errorf("error reading %s: %w", l.Src, err)
 		}
 
-	default:
-		err := CopyFile(l.Src, l.Dst())
+		l.Meta, src = ParseMeta(src)
+
+		r, err := l.render(src)
 		if err != nil {
-			return fmt.Errorf("error copying %s to %s: %w", l.Src, l.Dst(), err)
+			return err
+		}
+
+		if err := l.renderPage(g, r); err != nil {
+			return err
+		}
+	} else if l.Fingerprint != "" && isStylesheet(l.Src) {
+		if err := l.writeStylesheet(g); err != nil {
+			return err
+		}
+	} else if l.Symlink {
+		if err := CopyLink(l.Src, l.Dst()); err != nil {
+			return err
 		}
+	} else if err := CopyFile(l.Src, l.Dst()); err != nil {
+		return fmt.Errorf("error copying %s to %s: %w", l.Src, l.Dst(), err)
+	}
+
+	if g.capsule != nil {
+		return l.growCapsule(g)
 	}
 
 	return nil
 }
+
+// render converts the source of a page into HTML with the Renderer of its
+// type.  The title is taken from the leaf's metadata when it has one.
+func (l Leaf) render(src []byte) (Rendered, error) {
+	renderer := DefaultRegistry.Renderer(l.Typ)
+	if renderer == nil {
+		return Rendered{Body: src}, nil
+	}
+
+	r, err := renderer.Render(src)
+	if err != nil {
+		return Rendered{}, fmt.Errorf("error rendering %s: %w", l.Src, err)
+	}
+
+	r.Title = l.titleOr(r.Title)
+
+	return r, nil
+}
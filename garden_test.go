@@ -49,6 +49,12 @@ func TestBranchScan(t *testing.T) {
 						Path:   "/example/mydoc.md",
 						Typ:    gdn.Markdown,
 					},
+					{
+						Src:    testsrc + "/example/mygemini.gmi",
+						DstDir: "tmp/example",
+						Path:   "/example/mygemini.gmi",
+						Typ:    gdn.Gemini,
+					},
 					{
 						Src:    testsrc + "/example/mytext.txt",
 						DstDir: "tmp/example",
@@ -144,6 +150,15 @@ func TestLeafDst(t *testing.T) {
 			},
 			"qwer/my.html",
 		},
+		{
+			gdn.Leaf{
+				Src:    "asdf/my.gmi",
+				DstDir: "qwer",
+				Path:   "/my.gmi",
+				Typ:    gdn.Gemini,
+			},
+			"qwer/my.html",
+		},
 		{
 			gdn.Leaf{
 				Src:    "asdf/my.txt",
@@ -164,6 +179,25 @@ func TestLeafDst(t *testing.T) {
 	}
 }
 
+func TestLeafURL(t *testing.T) {
+	tbls := []struct {
+		leaf     gdn.Leaf
+		expected string
+	}{
+		{gdn.Leaf{Path: "/a/my.md", Typ: gdn.Markdown}, "/a/my.html"},
+		{gdn.Leaf{Path: "/a/my.gmi", Typ: gdn.Gemini}, "/a/my.html"},
+		{gdn.Leaf{Path: "/a/my.txt", Typ: gdn.Unknown}, "/a/my.txt"},
+	}
+
+	for _, tbl := range tbls {
+		result := tbl.leaf.URL()
+		if result != tbl.expected {
+			t.Errorf("Leaf %+v .URL() gave: %s, expecting: %s",
+				tbl.leaf, result, tbl.expected)
+		}
+	}
+}
+
 func TestLeafGrow(t *testing.T) {
 	t.Log("-test ensures error is given when source path is not set")
 
@@ -0,0 +1,3 @@
+// Package gemini provides a server for the Gemini protocol that serves the
+// files of a directory, such as a capsule grown by gdn.
+package gemini
@@ -0,0 +1,292 @@
+package gemini
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status codes sent by the server.
+const (
+	StatusSuccess           = 20 // the file follows the header
+	StatusRedirectPermanent = 31 // the file has moved to the given URL
+	StatusNotFound          = 51 // there is no file at the URL
+	StatusBadRequest        = 59 // the request could not be understood
+)
+
+// MaxRequestLen is the maximum length of the URL in a request.
+const MaxRequestLen = 1024
+
+// requestTimeout is how long a client has to send its request.
+const requestTimeout = 10 * time.Second
+
+// IndexFiles are the names of the files served for a directory, in the order
+// they are looked for.
+var IndexFiles = []string{ // nolint: gochecknoglobals
+	"index.gmi", "index.gemini",
+}
+
+var (
+	// ErrServerClosed is returned by Serve and ListenAndServe after the server
+	// is closed.
+	ErrServerClosed = errors.New("gemini: server closed")
+	// ErrNoCertificate is returned by Serve and ListenAndServe when the server
+	// has no TLSConfig.
+	ErrNoCertificate = errors.New("gemini: server has no TLS config")
+)
+
+// Errors sent to the client when a request is not understood.
+var (
+	errNoCRLF   = errors.New("request must be a URL ending in CRLF")
+	errNotAbs   = errors.New("request must be an absolute URL")
+	errScheme   = errors.New("only gemini URLs are served")
+	errUserInfo = errors.New("URL must not have user info")
+)
+
+// Server serves the files within a directory over the Gemini protocol.
+// Directories are served by their index file.  Hidden files are never served.
+type Server struct {
+	// Addr is the TCP address to listen on.  When empty, ":1965" is used.
+	Addr string
+	// Root is the directory being served.
+	Root string
+	// TLSConfig holds the certificate of the server.
+	TLSConfig *tls.Config
+
+	mu        sync.Mutex
+	listeners map[net.Listener]bool
+	closed    bool
+}
+
+// ListenAndServe listens on the server's Addr and serves requests until the
+// server is closed.
+func (s *Server) ListenAndServe() error {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":1965"
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", addr, err)
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accepts connections from the listener, wrapping each in TLS, and serves
+// requests until the server is closed.  The listener is closed on return.
+func (s *Server) Serve(l net.Listener) error {
+	if s.TLSConfig == nil {
+		return ErrNoCertificate
+	}
+
+	cfg := s.TLSConfig.Clone()
+	if cfg.MinVersion < tls.VersionTLS12 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+
+	l = tls.NewListener(l, cfg)
+	defer l.Close()
+
+	if !s.track(l) {
+		return ErrServerClosed
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Temporary() {
+				continue
+			}
+
+			return fmt.Errorf("gemini: could not accept connection: %w", err)
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops the server from accepting connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	var err error
+
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	s.listeners = nil
+
+	return err
+}
+
+// track adds the listener to those closed by Close.  It returns false if the
+// server is already closed.
+func (s *Server) track(l net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]bool)
+	}
+
+	s.listeners[l] = true
+
+	return true
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+// serveConn answers the single request of the connection and closes it.
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(requestTimeout)) // nolint: errcheck
+
+	u, err := readRequest(conn)
+	if err != nil {
+		writeHeader(conn, StatusBadRequest, err.Error())
+		return
+	}
+
+	s.serveFile(conn, u)
+}
+
+// readRequest reads the URL requested by the client.
+func readRequest(r io.Reader) (*url.URL, error) {
+	// The URL is followed by CRLF, which must fit in the buffer.
+	br := bufio.NewReaderSize(io.LimitReader(r, MaxRequestLen+2),
+		MaxRequestLen+2)
+
+	line, err := br.ReadString('\n')
+	if err != nil || !strings.HasSuffix(line, "\r\n") {
+		return nil, errNoCRLF
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(line, "\r\n"))
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return nil, errNotAbs
+	}
+
+	if u.Scheme != "gemini" {
+		return nil, errScheme
+	}
+
+	if u.User != nil {
+		return nil, errUserInfo
+	}
+
+	return u, nil
+}
+
+// serveFile writes the response for the file at the URL.
+func (s *Server) serveFile(w io.Writer, u *url.URL) {
+	urlPath := path.Clean("/" + u.Path)
+
+	for _, name := range strings.Split(urlPath, "/") {
+		if strings.HasPrefix(name, ".") {
+			writeHeader(w, StatusNotFound, "not found")
+			return
+		}
+	}
+
+	name := filepath.Join(s.Root, filepath.FromSlash(urlPath))
+
+	info, err := os.Stat(name)
+	if err != nil {
+		writeHeader(w, StatusNotFound, "not found")
+		return
+	}
+
+	if info.IsDir() {
+		if !strings.HasSuffix(u.Path, "/") {
+			to := *u
+			to.Path = strings.TrimSuffix(urlPath, "/") + "/"
+			writeHeader(w, StatusRedirectPermanent, to.String())
+
+			return
+		}
+
+		name = index(name)
+		if name == "" {
+			writeHeader(w, StatusNotFound, "not found")
+			return
+		}
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		writeHeader(w, StatusNotFound, "not found")
+		return
+	}
+	defer f.Close()
+
+	writeHeader(w, StatusSuccess, MIMEType(name))
+	io.Copy(w, f) // nolint: errcheck // nothing to tell the client
+}
+
+// index returns the index file of the directory, or an empty string if it has
+// none.
+func index(dir string) string {
+	for _, name := range IndexFiles {
+		file := filepath.Join(dir, name)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file
+		}
+	}
+
+	return ""
+}
+
+// writeHeader writes the response header with the status and meta.
+func writeHeader(w io.Writer, status int, meta string) {
+	fmt.Fprintf(w, "%d %s\r\n", status, meta)
+}
+
+// MIMEType returns the MIME type of the file with the given name, based on its
+// extension.  Gemini text is "text/gemini", and files with an unknown extension
+// are "application/octet-stream".
+func MIMEType(name string) string {
+	switch ext := strings.ToLower(path.Ext(name)); ext {
+	case ".gmi", ".gemini":
+		return "text/gemini; charset=utf-8"
+	default:
+		if typ := mime.TypeByExtension(ext); typ != "" {
+			return typ
+		}
+
+		return "application/octet-stream"
+	}
+}
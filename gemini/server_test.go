@@ -0,0 +1,204 @@
+package gemini_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn/gemini"
+)
+
+// serve starts a server for the root directory and returns its address along
+// with a function to stop it.
+func serve(t *testing.T, root string) (string, func()) {
+	cert, err := gemini.NewCertificate("127.0.0.1")
+	if err != nil {
+		t.Fatalf("could not generate certificate: %v", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+
+	s := &gemini.Server{
+		Root:      root,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+
+	done := make(chan error, 1)
+
+	go func() { done <- s.Serve(l) }()
+
+	return l.Addr().String(), func() {
+		if err := s.Close(); err != nil {
+			t.Errorf("could not close server: %v", err)
+		}
+
+		if err := <-done; !errors.Is(err, gemini.ErrServerClosed) {
+			t.Errorf("expected %v, got: %v", gemini.ErrServerClosed, err)
+		}
+	}
+}
+
+// request sends the request to the server and returns the whole response.
+func request(t *testing.T, addr, req string) string {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		InsecureSkipVerify: true, // nolint: gosec // self-signed
+	})
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatalf("could not send request: %v", err)
+	}
+
+	resp, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("could not read response: %v", err)
+	}
+
+	return string(resp)
+}
+
+func TestServer(t *testing.T) {
+	root, err := ioutil.TempDir("", "gemini")
+	if err != nil {
+		t.Fatalf("could not create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"index.gmi":       "# Home\n",
+		"notes/index.gmi": "# Notes\n",
+		"notes/a.html":    "<p>text</p>",
+		"empty/x.png":     "png",
+		".secret":         "hidden",
+	}
+
+	for name, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o750); err != nil {
+			t.Fatalf("could not make dir: %v", err)
+		}
+
+		if err := ioutil.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	addr, stop := serve(t, root)
+	defer stop()
+
+	tbls := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{
+			"root",
+			"gemini://localhost/\r\n",
+			"20 text/gemini; charset=utf-8\r\n# Home\n",
+		},
+		{
+			"root without slash",
+			"gemini://localhost\r\n",
+			"31 gemini://localhost/\r\n",
+		},
+		{
+			"directory",
+			"gemini://localhost/notes/\r\n",
+			"20 text/gemini; charset=utf-8\r\n# Notes\n",
+		},
+		{
+			"directory without slash",
+			"gemini://localhost/notes?q#f\r\n",
+			"31 gemini://localhost/notes/?q#f\r\n",
+		},
+		{
+			"directory without index",
+			"gemini://localhost/empty/\r\n",
+			"51 not found\r\n",
+		},
+		{
+			"file",
+			"gemini://localhost/notes/a.html\r\n",
+			"20 text/html; charset=utf-8\r\n<p>text</p>",
+		},
+		{
+			"image",
+			"gemini://localhost/empty/x.png\r\n",
+			"20 image/png\r\npng",
+		},
+		{
+			"missing",
+			"gemini://localhost/missing.gmi\r\n",
+			"51 not found\r\n",
+		},
+		{
+			"hidden",
+			"gemini://localhost/.secret\r\n",
+			"51 not found\r\n",
+		},
+		{
+			"outside root",
+			"gemini://localhost/../../etc/passwd\r\n",
+			"51 not found\r\n",
+		},
+		{
+			"no CRLF",
+			"gemini://localhost/\n",
+			"59 request must be a URL ending in CRLF\r\n",
+		},
+		{
+			"relative",
+			"/index.gmi\r\n",
+			"59 request must be an absolute URL\r\n",
+		},
+		{
+			"other scheme",
+			"https://localhost/\r\n",
+			"59 only gemini URLs are served\r\n",
+		},
+		{
+			"too long",
+			"gemini://localhost/" + string(make([]byte, 1024)) + "\r\n",
+			"59 request must be a URL ending in CRLF\r\n",
+		},
+	}
+
+	for _, tbl := range tbls {
+		tbl := tbl
+		t.Run(tbl.name, func(t *testing.T) {
+			actual := request(t, addr, tbl.request)
+			if actual != tbl.expected {
+				t.Errorf("expected %q, got: %q", tbl.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMIMEType(t *testing.T) {
+	tbls := []struct {
+		name     string
+		expected string
+	}{
+		{"page.gmi", "text/gemini; charset=utf-8"},
+		{"page.GEMINI", "text/gemini; charset=utf-8"},
+		{"page.html", "text/html; charset=utf-8"},
+		{"unknown.zzz", "application/octet-stream"},
+	}
+
+	for _, tbl := range tbls {
+		actual := gemini.MIMEType(tbl.name)
+		if actual != tbl.expected {
+			t.Errorf("%s: expected %q, got: %q", tbl.name, tbl.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package gmi_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+// conformance are the cases every reader of Gemini text in this package must
+// agree on.  Input is parsed into the lines, which are written back as the
+// canonical text.
+var conformance = []struct { // nolint: gochecknoglobals
+	name      string
+	input     string
+	lines     []gmi.Line
+	canonical string
+}{
+	{
+		"headings",
+		"# One\n##Two  \n###   Three\n#### Four",
+		[]gmi.Line{
+			gmi.HeadingLine{Level: 1, Text: "One"},
+			gmi.HeadingLine{Level: 2, Text: "Two"},
+			gmi.HeadingLine{Level: 3, Text: "Three"},
+			gmi.HeadingLine{Level: 3, Text: "# Four"},
+		},
+		"# One\n## Two\n### Three\n### # Four\n",
+	},
+	{
+		"text keeps whitespace at its start",
+		"  indented\ttext  \n \t\nlast",
+		[]gmi.Line{
+			gmi.TextLine{Text: "  indented\ttext"},
+			gmi.TextLine{Text: ""},
+			gmi.TextLine{Text: "last"},
+		},
+		"  indented\ttext\n\nlast\n",
+	},
+	{
+		"links",
+		"=>a.gmi\n=> \tb.gmi \t Text  with  spaces \n=>",
+		[]gmi.Line{
+			gmi.LinkLine{URL: "a.gmi"},
+			gmi.LinkLine{URL: "b.gmi", Text: "Text  with  spaces"},
+			gmi.LinkLine{},
+		},
+		"=> a.gmi\n=> b.gmi Text  with  spaces\n=> \n",
+	},
+	{
+		"list items need a space",
+		"* one\n*two\n*",
+		[]gmi.Line{
+			gmi.ListLine{Text: "one"},
+			gmi.TextLine{Text: "*two"},
+			gmi.TextLine{Text: "*"},
+		},
+		"* one\n*two\n*\n",
+	},
+	{
+		"list items are trimmed",
+		"* item  \n*   spaced\t",
+		[]gmi.Line{
+			gmi.ListLine{Text: "item"},
+			gmi.ListLine{Text: "spaced"},
+		},
+		"* item\n* spaced\n",
+	},
+	{
+		"quotes",
+		">one\n>   two\n>",
+		[]gmi.Line{
+			gmi.QuoteLine{Text: "one"},
+			gmi.QuoteLine{Text: "two"},
+			gmi.QuoteLine{Text: ""},
+		},
+		"> one\n> two\n> \n",
+	},
+	{
+		"quotes are trimmed",
+		"> q  \n>\t",
+		[]gmi.Line{
+			gmi.QuoteLine{Text: "q"},
+			gmi.QuoteLine{Text: ""},
+		},
+		"> q\n> \n",
+	},
+	{
+		"preformatted",
+		"```alt text  \n# not a heading\n  => kept  \n```ignored\n* list",
+		[]gmi.Line{
+			gmi.PreStartLine{Alt: "alt text"},
+			gmi.PreBodyLine{Text: "# not a heading"},
+			gmi.PreBodyLine{Text: "  => kept  "},
+			gmi.PreEndLine{},
+			gmi.ListLine{Text: "list"},
+		},
+		"```alt text\n# not a heading\n  => kept  \n```\n* list\n",
+	},
+	{
+		"unterminated preformatted",
+		"```\ntext",
+		[]gmi.Line{gmi.PreStartLine{}, gmi.PreBodyLine{Text: "text"}},
+		"```\ntext\n",
+	},
+	{
+		"CRLF line endings",
+		"# Title\r\n=> url text\r\n",
+		[]gmi.Line{
+			gmi.HeadingLine{Level: 1, Text: "Title"},
+			gmi.LinkLine{URL: "url", Text: "text"},
+		},
+		"# Title\n=> url text\n",
+	},
+	{"empty", "", nil, ""},
+}
+
+func TestConformance(t *testing.T) {
+	for _, tbl := range conformance {
+		t.Run(tbl.name, func(t *testing.T) {
+			doc, err := gmi.Parse(strings.NewReader(tbl.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			t.Log("+test the document has the expected lines")
+
+			if !reflect.DeepEqual(doc.Lines, tbl.lines) {
+				t.Errorf("Parse gave: %#v, expecting: %#v",
+					doc.Lines, tbl.lines)
+			}
+
+			t.Log("+test the scanner agrees on the type of each line")
+
+			var types []gmi.LineType
+
+			s := gmi.NewScanner(strings.NewReader(tbl.input))
+			for s.Scan() {
+				types = append(types, s.Type())
+			}
+
+			for i, l := range tbl.lines {
+				if i >= len(types) || types[i] != l.Type() {
+					t.Errorf("line %d: scanner gave: %v, expecting: %v",
+						i+1, types, l.Type())
+
+					break
+				}
+			}
+
+			t.Log("+test the document is written in the canonical form")
+
+			var buf bytes.Buffer
+
+			n, err := doc.WriteTo(&buf)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if buf.String() != tbl.canonical || doc.String() != tbl.canonical {
+				t.Errorf("WriteTo gave: %q, expecting: %q",
+					buf.String(), tbl.canonical)
+			}
+
+			if int(n) != buf.Len() {
+				t.Errorf("WriteTo returned %d bytes, but wrote %d",
+					n, buf.Len())
+			}
+
+			t.Log("+test the canonical form parses into the same document")
+
+			again, err := gmi.Parse(strings.NewReader(tbl.canonical))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(again.Lines, doc.Lines) {
+				t.Errorf("round trip gave: %#v, expecting: %#v",
+					again.Lines, doc.Lines)
+			}
+
+			t.Log("+test the title and table of contents agree")
+
+			title, err := gmi.Title(strings.NewReader(tbl.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if doc.Title() != title {
+				t.Errorf("Title gave: %q, expecting: %q", doc.Title(), title)
+			}
+
+			toc, err := gmi.TOC(strings.NewReader(tbl.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(doc.TOC(), toc) {
+				t.Errorf("TOC gave: %v, expecting: %v", doc.TOC(), toc)
+			}
+		})
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	input, err := ioutil.ReadFile(example)
+	if err != nil {
+		t.Fatalf("could not read file %s: %v", example, err)
+	}
+
+	doc, err := gmi.Parse(bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	again, err := gmi.Parse(strings.NewReader(doc.String()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(again, doc) {
+		t.Errorf("%s did not parse into the same document after writing it",
+			example)
+	}
+}
@@ -1,2 +1,3 @@
-// Package gmi provides tools for reading Gemini text and converting it to HTML.
+// Package gmi provides tools for reading, parsing and writing Gemini text and
+// converting it to HTML.
 package gmi
@@ -0,0 +1,252 @@
+package gmi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Document is Gemini text parsed into its lines.  It is built with a Scanner,
+// so the lines have the same types the Scanner gives them.
+//
+// Whitespace around the text of every line is removed, apart from preformatted
+// text, which is kept exactly as it is, and the start of text lines, where it
+// is part of the text.
+//
+// Writing a Document gives its Gemini text in a canonical form, where a space
+// follows the marker at the start of headings, list items, quotes and links,
+// and where the text after the ``` ending preformatted text is dropped.
+// Parsing the written text gives back the same Document.
+type Document struct {
+	Lines []Line
+}
+
+// Line is a line of a Document.  It is one of HeadingLine, TextLine, LinkLine,
+// PreStartLine, PreBodyLine, PreEndLine, ListLine or QuoteLine.
+type Line interface {
+	// Type returns the type of the line.
+	Type() LineType
+	// String returns the line as Gemini text without a line ending.
+	String() string
+}
+
+// HeadingLine is a heading.  The text has surrounding whitespace removed.
+type HeadingLine struct {
+	Level int // from 1 to MaxHeadingLevel
+	Text  string
+}
+
+// Type returns Head1, Head2 or Head3 for the level of the heading.
+func (l HeadingLine) Type() LineType {
+	switch l.Level {
+	case 1:
+		return Head1
+	case 2: // nolint: gomnd // level 2
+		return Head2
+	default:
+		return Head3
+	}
+}
+
+func (l HeadingLine) String() string {
+	return strings.Repeat(tokHead1, l.Type().level()) + " " + l.Text
+}
+
+// TextLine is a line of normal text, which may be empty.  The text has
+// whitespace at its end removed.  Text starting with the marker of another line
+// type, such as # or =>, is written as is, so it is parsed as that type of
+// line.
+type TextLine struct {
+	Text string
+}
+
+// Type returns Text.
+func (l TextLine) Type() LineType { return Text }
+
+func (l TextLine) String() string { return l.Text }
+
+// LinkLine is a link to a URL, with optional text describing it.  The text has
+// surrounding whitespace removed.
+type LinkLine struct {
+	URL  string
+	Text string
+}
+
+// Type returns Link.
+func (l LinkLine) Type() LineType { return Link }
+
+func (l LinkLine) String() string {
+	if l.Text == "" {
+		return tokLink + " " + l.URL
+	}
+
+	return tokLink + " " + l.URL + " " + l.Text
+}
+
+// PreStartLine starts preformatted text.  Alt is the alternative text
+// following the ```, which describes the preformatted text, with surrounding
+// whitespace removed.
+type PreStartLine struct {
+	Alt string
+}
+
+// Type returns PreStart.
+func (l PreStartLine) Type() LineType { return PreStart }
+
+func (l PreStartLine) String() string { return tokPre + l.Alt }
+
+// PreBodyLine is a line of preformatted text, kept exactly as it is.
+type PreBodyLine struct {
+	Text string
+}
+
+// Type returns PreBody.
+func (l PreBodyLine) Type() LineType { return PreBody }
+
+func (l PreBodyLine) String() string { return l.Text }
+
+// PreEndLine ends preformatted text.
+type PreEndLine struct{}
+
+// Type returns PreEnd.
+func (l PreEndLine) Type() LineType { return PreEnd }
+
+func (l PreEndLine) String() string { return tokPre }
+
+// ListLine is an item of an unordered list.  The text has surrounding
+// whitespace removed.
+type ListLine struct {
+	Text string
+}
+
+// Type returns List.
+func (l ListLine) Type() LineType { return List }
+
+func (l ListLine) String() string { return tokList + l.Text }
+
+// QuoteLine is a line of quoted text.  The text has surrounding whitespace
+// removed.
+type QuoteLine struct {
+	Text string
+}
+
+// Type returns Quote.
+func (l QuoteLine) Type() LineType { return Quote }
+
+func (l QuoteLine) String() string { return tokQuote + " " + l.Text }
+
+// level returns the level of a heading line type, or 0 for other types.
+func (typ LineType) level() int {
+	switch typ { // nolint: exhaustive // only headings have levels
+	case Head1:
+		return 1
+	case Head2:
+		return 2 // nolint: gomnd // level 2
+	case Head3:
+		return MaxHeadingLevel
+	default:
+		return 0
+	}
+}
+
+// Parse reads Gemini text from r into a Document.
+func Parse(r io.Reader) (*Document, error) {
+	d := &Document{}
+	s := NewScanner(r)
+
+	for s.Scan() {
+		d.Lines = append(d.Lines, scannedLine(s))
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("error parsing Gemini text: %w", err)
+	}
+
+	return d, nil
+}
+
+// scannedLine returns the line just scanned by the Scanner.
+func scannedLine(s *Scanner) Line {
+	switch typ := s.Type(); typ {
+	case Head1, Head2, Head3:
+		return HeadingLine{
+			Level: typ.level(),
+			Text:  strings.TrimSpace(s.Text()),
+		}
+	case Link:
+		return LinkLine{URL: s.URL(), Text: strings.TrimSpace(s.Text())}
+	case PreStart:
+		return PreStartLine{Alt: strings.TrimSpace(s.Text())}
+	case PreBody:
+		return PreBodyLine{Text: s.Text()}
+	case PreEnd:
+		return PreEndLine{}
+	case List:
+		return ListLine{Text: strings.TrimSpace(s.Text())}
+	case Quote:
+		return QuoteLine{Text: strings.TrimSpace(s.Text())}
+	case Text:
+		return TextLine{Text: strings.TrimRightFunc(s.Text(), unicode.IsSpace)}
+	default:
+		return TextLine{Text: strings.TrimRightFunc(s.Text(), unicode.IsSpace)}
+	}
+}
+
+// WriteTo writes the Document to w as Gemini text, ending each line with a
+// line feed.  It returns the number of bytes written.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	buf := bufio.NewWriter(w)
+
+	var n int64
+
+	for _, l := range d.Lines {
+		m, _ := buf.WriteString(l.String()) // nolint: errcheck // in Flush
+		n += int64(m)
+
+		buf.WriteByte('\n') // nolint: errcheck // in Flush
+		n++
+	}
+
+	if err := buf.Flush(); err != nil {
+		return n, fmt.Errorf("error writing Gemini text: %w", err)
+	}
+
+	return n, nil
+}
+
+// String returns the Document as Gemini text.  See WriteTo.
+func (d *Document) String() string {
+	var b strings.Builder
+
+	d.WriteTo(&b) // nolint: errcheck // never fails writing to a Builder
+
+	return b.String()
+}
+
+// Title returns the text of the first level 1 heading, which is the same as
+// the title given by the Title function.
+func (d *Document) Title() string {
+	for _, l := range d.Lines {
+		if h, ok := l.(HeadingLine); ok && h.Level == 1 {
+			return h.Text
+		}
+	}
+
+	return ""
+}
+
+// TOC returns the table of contents of the headings in the Document, which is
+// the same as the one given by the TOC function.
+func (d *Document) TOC() []Heading {
+	var c Contents
+
+	for _, l := range d.Lines {
+		if h, ok := l.(HeadingLine); ok {
+			c.Add(h.Level, h.Text)
+		}
+	}
+
+	return c.Headings
+}
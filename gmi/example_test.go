@@ -33,3 +33,37 @@ func ExampleScanner() {
 	// line 2: Text: This is a line of text.
 	// line 3: Link: url gemini://gemini.circumlunar.space/: Gemini
 }
+
+// Using ToHTML to render Gemini text as HTML.
+func ExampleToHTML() {
+	err := gmi.ToHTML(os.Stdout, strings.NewReader(geminiText))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	// Output: <h1>Example Gemini</h1>
+	// <p>This is a line of text.</p>
+	// <p><a href="gemini://gemini.circumlunar.space/">Gemini</a></p>
+}
+
+// Using Parse to change the links of a Document and write it back out.
+func ExampleParse() {
+	doc, err := gmi.Parse(strings.NewReader(geminiText))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	for i, line := range doc.Lines {
+		if link, ok := line.(gmi.LinkLine); ok {
+			link.Text = strings.ToUpper(link.Text)
+			doc.Lines[i] = link
+		}
+	}
+
+	fmt.Print(doc)
+
+	// Output: # Example Gemini
+	// This is a line of text.
+	// => gemini://gemini.circumlunar.space/ GEMINI
+}
@@ -0,0 +1,171 @@
+package gmi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+)
+
+// block is the kind of HTML block element that is open while rendering.
+type block int
+
+const (
+	blockNone block = iota
+	blockList
+	blockQuote
+	blockPre
+)
+
+// ToHTML reads Gemini text from r and writes it to w as HTML.  It is the same
+// as calling Render on an HTMLRenderer with no options set.
+func ToHTML(w io.Writer, r io.Reader) error {
+	return HTMLRenderer{}.Render(w, r)
+}
+
+// HTMLRenderer renders Gemini text as HTML.
+type HTMLRenderer struct {
+	// LinkURL, if set, is called with the URL of each link line and returns
+	// the URL to use for the link in the HTML.  For example, it can be used to
+	// point links to Gemini pages to the HTML pages generated from them.
+	LinkURL func(url string) string
+	// HeadingIDs gives each heading an id attribute so it can be linked to.
+	// The IDs are the same as those in the table of contents from TOC.
+	HeadingIDs bool
+}
+
+// Render reads Gemini text from r and writes it to w as HTML.  Headings,
+// paragraphs, links, lists, quotes and preformatted text are rendered to their
+// semantic HTML elements.  Consecutive list and quote lines are grouped into a
+// single list or quote.  Empty text lines are not rendered since paragraphs
+// already provide the spacing between lines.
+//
+// Only the HTML body fragment is written.  It is not wrapped in any <html> or
+// <body> elements.
+func (h HTMLRenderer) Render(w io.Writer, r io.Reader) error {
+	buf := bufio.NewWriter(w)
+	s := NewScanner(r)
+	open := blockNone
+
+	var toc Contents
+
+	for s.Scan() {
+		open = closeBlock(buf, open, s.Type())
+
+		switch s.Type() {
+		case Head1:
+			h.writeHeading(buf, &toc, 1, s.TextBytes())
+		case Head2:
+			h.writeHeading(buf, &toc, 2, s.TextBytes())
+		case Head3:
+			h.writeHeading(buf, &toc, 3, s.TextBytes())
+		case Text:
+			if len(s.TextBytes()) > 0 {
+				fmt.Fprintf(buf, "<p>%s</p>\n", escape(s.TextBytes()))
+			}
+		case Link:
+			h.writeLink(buf, s.URLBytes(), s.TextBytes())
+		case List:
+			if open != blockList {
+				buf.WriteString("<ul>\n")
+				open = blockList
+			}
+
+			fmt.Fprintf(buf, "<li>%s</li>\n", escape(s.TextBytes()))
+		case Quote:
+			if open != blockQuote {
+				buf.WriteString("<blockquote>\n")
+				open = blockQuote
+			}
+
+			fmt.Fprintf(buf, "<p>%s</p>\n",
+				escape(trimLeftSpace(s.TextBytes())))
+		case PreStart:
+			writePreStart(buf, s.TextBytes())
+			open = blockPre
+		case PreBody:
+			fmt.Fprintf(buf, "%s\n", escape(s.TextBytes()))
+		case PreEnd:
+			buf.WriteString("</pre>\n")
+			open = blockNone
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("error scanning Gemini text: %w", err)
+	}
+
+	// Close anything left open, such as preformatted text without an ending.
+	closeBlock(buf, open, Text)
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("error writing HTML: %w", err)
+	}
+
+	return nil
+}
+
+// closeBlock writes the closing tag for the open block if the next line type
+// does not continue it.  It returns the block that is still open afterwards.
+func closeBlock(w *bufio.Writer, open block, next LineType) block {
+	switch {
+	case open == blockList && next != List:
+		w.WriteString("</ul>\n")
+	case open == blockQuote && next != Quote:
+		w.WriteString("</blockquote>\n")
+	case open == blockPre && next != PreBody && next != PreEnd:
+		w.WriteString("</pre>\n")
+	default:
+		return open
+	}
+
+	return blockNone
+}
+
+// writeHeading writes a heading of the given level, adding it to the table of
+// contents to give it an ID if HeadingIDs is set.
+func (h HTMLRenderer) writeHeading(w *bufio.Writer, toc *Contents, level int,
+	text []byte) {
+	if !h.HeadingIDs {
+		fmt.Fprintf(w, "<h%d>%s</h%d>\n", level, escape(text), level)
+		return
+	}
+
+	id := toc.Add(level, string(text)).ID
+	fmt.Fprintf(w, "<h%d id=\"%s\">%s</h%d>\n", level, id, escape(text), level)
+}
+
+// writeLink writes a link line as a paragraph containing an anchor.  The URL is
+// used as the anchor text when the link has no description.
+func (h HTMLRenderer) writeLink(w *bufio.Writer, url, text []byte) {
+	if len(text) == 0 {
+		text = url
+	}
+
+	href := string(url)
+	if h.LinkURL != nil {
+		href = h.LinkURL(href)
+	}
+
+	fmt.Fprintf(w, "<p><a href=\"%s\">%s</a></p>\n",
+		html.EscapeString(href), escape(text))
+}
+
+// writePreStart opens a preformatted block.  Any alternative text after the
+// opening ``` is used as the accessible label of the block.
+func writePreStart(w *bufio.Writer, alt []byte) {
+	alt = bytes.Trim(alt, whitespace)
+	if len(alt) == 0 {
+		w.WriteString("<pre>")
+		return
+	}
+
+	fmt.Fprintf(w, "<pre aria-label=\"%s\">", escape(alt))
+}
+
+// escape escapes text so it is safe to place within HTML elements and quoted
+// attribute values.
+func escape(b []byte) string {
+	return html.EscapeString(string(b))
+}
@@ -0,0 +1,107 @@
+package gmi_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+func TestToHTML(t *testing.T) {
+	tbls := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"heading 1", "# Title", "<h1>Title</h1>\n"},
+		{"heading 2", "## Sub", "<h2>Sub</h2>\n"},
+		{"heading 3", "###Sub sub", "<h3>Sub sub</h3>\n"},
+		{"text", "Some text.", "<p>Some text.</p>\n"},
+		{"empty text", "\n\n", ""},
+		{"escaped text", "a < b & c", "<p>a &lt; b &amp; c</p>\n"},
+		{
+			"link",
+			"=> gemini://example.tld/ Example",
+			"<p><a href=\"gemini://example.tld/\">Example</a></p>\n",
+		},
+		{
+			"link without text",
+			"=> https://example.tld/",
+			"<p><a href=\"https://example.tld/\">" +
+				"https://example.tld/</a></p>\n",
+		},
+		{
+			"list",
+			"* one\n* two\ntext",
+			"<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<p>text</p>\n",
+		},
+		{
+			"quote",
+			"> one\n>two",
+			"<blockquote>\n<p>one</p>\n<p>two</p>\n</blockquote>\n",
+		},
+		{
+			"preformatted",
+			"```go\nfunc <main>\n```ignored",
+			"<pre aria-label=\"go\">func &lt;main&gt;\n</pre>\n",
+		},
+		{
+			"unterminated preformatted",
+			"```\n* not a list",
+			"<pre>* not a list\n</pre>\n",
+		},
+	}
+
+	for _, tbl := range tbls {
+		var buf bytes.Buffer
+
+		if err := gmi.ToHTML(&buf, strings.NewReader(tbl.input)); err != nil {
+			t.Errorf("%s: unexpected error: %v", tbl.name, err)
+			continue
+		}
+
+		if buf.String() != tbl.expected {
+			t.Errorf("%s: ToHTML(%q) gave: %q, expecting: %q",
+				tbl.name, tbl.input, buf.String(), tbl.expected)
+		}
+	}
+}
+
+func TestHTMLRendererLinkURL(t *testing.T) {
+	var buf bytes.Buffer
+
+	r := gmi.HTMLRenderer{
+		LinkURL: func(url string) string { return strings.ToUpper(url) },
+	}
+
+	input := "=> a.gmi\n=> b.gmi B"
+	if err := r.Render(&buf, strings.NewReader(input)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "<p><a href=\"A.GMI\">a.gmi</a></p>\n" +
+		"<p><a href=\"B.GMI\">B</a></p>\n"
+	if buf.String() != expected {
+		t.Errorf("Render gave: %q, expecting: %q", buf.String(), expected)
+	}
+}
+
+func TestHTMLRendererHeadingIDs(t *testing.T) {
+	var buf bytes.Buffer
+
+	r := gmi.HTMLRenderer{HeadingIDs: true}
+
+	input := "# Hello, World!\n## Notes\n## Notes\n### <>"
+	if err := r.Render(&buf, strings.NewReader(input)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "<h1 id=\"hello-world\">Hello, World!</h1>\n" +
+		"<h2 id=\"notes\">Notes</h2>\n" +
+		"<h2 id=\"notes-1\">Notes</h2>\n" +
+		"<h3 id=\"section\">&lt;&gt;</h3>\n"
+	if buf.String() != expected {
+		t.Errorf("Render gave: %q, expecting: %q", buf.String(), expected)
+	}
+}
@@ -0,0 +1,141 @@
+package gmi
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"unicode/utf8"
+)
+
+// Severity is how serious a problem found by Lint is.
+type Severity int
+
+const (
+	// SeverityError is a problem that breaks the document, such as a link
+	// that cannot be followed.
+	SeverityError Severity = iota + 1
+	// SeverityWarning is a problem that is likely a mistake, but still gives
+	// a working document.
+	SeverityWarning
+)
+
+// String returns the string representation of the severity.  For example, for
+// SeverityError it will return the string "error".
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the severity as its string representation, such as in
+// JSON.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Diagnostic is a problem found in a document.
+type Diagnostic struct {
+	// File is the name of the document the problem is in.
+	File string `json:"file"`
+	// Line is the number of the line the problem is on, starting at 1.
+	Line int `json:"line"`
+	// Column is the number of the character in the line the problem starts
+	// at, starting at 1.
+	Column   int      `json:"column"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// String returns the diagnostic in the form file:line:column: severity:
+// message.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s",
+		d.File, d.Line, d.Column, d.Severity, d.Message)
+}
+
+// Lint reads Gemini text from r and returns the problems found in it, in the
+// order of the lines they are on.  The name is used as the File of each
+// Diagnostic.  The problems found are:
+//
+//     - preformatted text that is never ended
+//     - links with an empty URL
+//     - links with a URL that cannot be parsed
+//     - headings with no text
+func Lint(name string, r io.Reader) ([]Diagnostic, error) {
+	var (
+		diags []Diagnostic
+		pre   int // line the open preformatted text starts on
+	)
+
+	report := func(line, col int, sev Severity, format string,
+		args ...interface{}) {
+		diags = append(diags, Diagnostic{
+			File:     name,
+			Line:     line,
+			Column:   col,
+			Severity: sev,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	s := NewScanner(r)
+	for s.Scan() {
+		raw := s.scan.Bytes() // the whole line, to find columns in
+
+		switch s.Type() { // nolint: exhaustive // other lines have no problems
+		case PreStart:
+			pre = s.Line()
+		case PreEnd:
+			pre = 0
+		case Head1, Head2, Head3:
+			if isBlank(s.TextBytes()) {
+				report(s.Line(), 1, SeverityWarning, "heading has no text")
+			}
+		case Link:
+			if len(s.URLBytes()) == 0 {
+				report(s.Line(), 1, SeverityError, "link has no URL")
+			} else if _, err := url.Parse(s.URL()); err != nil {
+				// The URL starts after the => and any whitespace.
+				start := len(raw) - len(trimLeftSpace(raw[len(tokLink):]))
+				report(s.Line(), column(raw, start), SeverityError,
+					"invalid URL %q: %v", s.URL(), unwrapURLError(err))
+			}
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return diags, fmt.Errorf("error scanning %s: %w", name, err)
+	}
+
+	if pre != 0 {
+		report(pre, 1, SeverityWarning,
+			"preformatted text is not ended with ```")
+	}
+
+	return diags, nil
+}
+
+// column returns the column of the character at the byte offset in the line.
+func column(line []byte, offset int) int {
+	return utf8.RuneCount(line[:offset]) + 1
+}
+
+// isBlank returns whether the text is only whitespace.
+func isBlank(b []byte) bool {
+	return len(trimLeftSpace(b)) == 0
+}
+
+// unwrapURLError returns the reason a URL could not be parsed, without the URL
+// that url.Error repeats.
+func unwrapURLError(err error) error {
+	if uerr, ok := err.(*url.Error); ok { // nolint: errorlint // not wrapped
+		return uerr.Err
+	}
+
+	return err
+}
@@ -0,0 +1,73 @@
+package gmi_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+func TestLint(t *testing.T) {
+	tbls := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"no problems", "# Title\n=> a.gmi A\n```\n=>\n```\n", nil},
+		{"empty heading", "#\n## \t\n### Text", []string{
+			"doc.gmi:1:1: warning: heading has no text",
+			"doc.gmi:2:1: warning: heading has no text",
+		}},
+		{"empty link", "text\n=>  \n=>", []string{
+			"doc.gmi:2:1: error: link has no URL",
+			"doc.gmi:3:1: error: link has no URL",
+		}},
+		{"invalid URL", "=> ok\n=>  héllo%zz text\n=>:\n", []string{
+			"doc.gmi:2:5: error: invalid URL \"héllo%zz\": " +
+				"invalid URL escape \"%zz\"",
+			"doc.gmi:3:3: error: invalid URL \":\": missing protocol scheme",
+		}},
+		{"unterminated preformatted", "# A\n```\n# B\n=>\n", []string{
+			"doc.gmi:2:1: warning: preformatted text is not ended with ```",
+		}},
+	}
+
+	for _, tbl := range tbls {
+		diags, err := gmi.Lint("doc.gmi", strings.NewReader(tbl.input))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tbl.name, err)
+			continue
+		}
+
+		var actual []string
+		for _, d := range diags {
+			actual = append(actual, d.String())
+		}
+
+		if !reflect.DeepEqual(actual, tbl.expected) {
+			t.Errorf("%s: Lint gave: %q, expecting: %q",
+				tbl.name, actual, tbl.expected)
+		}
+	}
+}
+
+func TestDiagnosticJSON(t *testing.T) {
+	b, err := json.Marshal(gmi.Diagnostic{
+		File:     "doc.gmi",
+		Line:     2,
+		Column:   3,
+		Severity: gmi.SeverityWarning,
+		Message:  "message",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `{"file":"doc.gmi","line":2,"column":3,"severity":"warning",` +
+		`"message":"message"}`
+	if string(b) != expected {
+		t.Errorf("JSON gave: %s, expecting: %s", b, expected)
+	}
+}
@@ -137,6 +137,21 @@ func BenchmarkToastParser(b *testing.B) {
 	b.ReportAllocs()
 }
 
+// BenchmarkParse benchmarks parsing into a Document, which builds a list of
+// lines like the toast.cafe/x/gmi parser.
+func BenchmarkParse(b *testing.B) {
+	input, err := ioutil.ReadFile(example)
+	if err != nil {
+		b.Fatalf("could not read file %s: %v", example, err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		gmi.Parse(bytes.NewReader(input)) // nolint: errcheck // for benchmark
+	}
+
+	b.ReportAllocs()
+}
+
 func expectEnd(t *testing.T, s *gmi.Scanner, num int) {
 	if s.Scan() {
 		t.Errorf("Line %d: scanner should be finished", s.Line())
@@ -0,0 +1,27 @@
+package gmi
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Title scans the Gemini text from r and returns the title of the document.
+// The title is the text of the first level 1 heading with surrounding
+// whitespace removed.  An empty string is returned if there is no level 1
+// heading.
+func Title(r io.Reader) (string, error) {
+	s := NewScanner(r)
+
+	for s.Scan() {
+		if s.Type() == Head1 {
+			return strings.TrimSpace(s.Text()), nil
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return "", fmt.Errorf("error scanning for title: %w", err)
+	}
+
+	return "", nil
+}
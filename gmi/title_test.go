@@ -0,0 +1,35 @@
+package gmi_test
+
+import (
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+func TestTitle(t *testing.T) {
+	tbls := []struct {
+		input    string
+		expected string
+	}{
+		{"# Title", "Title"},
+		{"#Title  ", "Title"},
+		{"Text\n## Sub\n# Title\n# Other", "Title"},
+		{"```\n# Not a title\n```\n# Title", "Title"},
+		{"## Sub\n### Sub sub", ""},
+		{"", ""},
+	}
+
+	for _, tbl := range tbls {
+		title, err := gmi.Title(strings.NewReader(tbl.input))
+		if err != nil {
+			t.Errorf("Title(%q) unexpected error: %v", tbl.input, err)
+			continue
+		}
+
+		if title != tbl.expected {
+			t.Errorf("Title(%q) gave: %q, expecting: %q",
+				tbl.input, title, tbl.expected)
+		}
+	}
+}
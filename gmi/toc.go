@@ -0,0 +1,151 @@
+package gmi
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MaxHeadingLevel is the deepest level of heading in Gemini text.
+const MaxHeadingLevel = 3
+
+// Heading is an entry in the table of contents of a document.
+type Heading struct {
+	// Level is the level of the heading, from 1 to MaxHeadingLevel.
+	Level int
+	// Text is the text of the heading with surrounding whitespace removed.
+	Text string
+	// Number is the section number of the heading at each level.  For
+	// example, {1, 3, 0} is the third level 2 heading after the first level 1
+	// heading.
+	Number [MaxHeadingLevel]int
+	// ID is the slug of the text, which is unique within the document, to
+	// link to the heading with.
+	ID string
+}
+
+// Numbered returns the section number of the heading, such as "1.3." for the
+// third level 2 heading after the first level 1 heading.
+func (h Heading) Numbered() string {
+	var b strings.Builder
+
+	for i := 0; i < h.Level && i < MaxHeadingLevel; i++ {
+		b.WriteString(strconv.Itoa(h.Number[i]))
+		b.WriteByte('.')
+	}
+
+	return b.String()
+}
+
+// String returns the numbered heading, such as "1.3. Text".
+func (h Heading) String() string {
+	return h.Numbered() + " " + h.Text
+}
+
+// Contents builds a table of contents one heading at a time, numbering each
+// heading and giving it a unique ID.  The zero value is ready to use.
+type Contents struct {
+	// Headings are the headings added so far.
+	Headings []Heading
+
+	number [MaxHeadingLevel]int
+	ids    map[string]bool
+}
+
+// Add adds a heading with the given level and text to the table of contents
+// and returns it.  Levels deeper than MaxHeadingLevel are numbered as
+// MaxHeadingLevel.
+func (c *Contents) Add(level int, text string) Heading {
+	if level < 1 {
+		level = 1
+	} else if level > MaxHeadingLevel {
+		level = MaxHeadingLevel
+	}
+
+	c.number[level-1]++
+	for i := level; i < MaxHeadingLevel; i++ {
+		c.number[i] = 0
+	}
+
+	text = strings.TrimSpace(text)
+	h := Heading{Level: level, Text: text, Number: c.number, ID: c.id(text)}
+	c.Headings = append(c.Headings, h)
+
+	return h
+}
+
+// id returns the slug of the text, adding a number to make it unique if it was
+// already used.
+func (c *Contents) id(text string) string {
+	if c.ids == nil {
+		c.ids = make(map[string]bool)
+	}
+
+	slug := Slug(text)
+	id := slug
+
+	for n := 1; c.ids[id]; n++ {
+		id = fmt.Sprintf("%s-%d", slug, n)
+	}
+
+	c.ids[id] = true
+
+	return id
+}
+
+// Slug returns an ID for a heading made from its text.  Letters are made lower
+// case, letters and digits are kept, and everything else becomes a single dash
+// between words.  Text without letters or digits gives "section".
+func Slug(text string) string {
+	var b strings.Builder
+
+	dash := false
+
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+
+			b.WriteRune(unicode.ToLower(r))
+
+			dash = false
+		default:
+			dash = true
+		}
+	}
+
+	if b.Len() == 0 {
+		return "section"
+	}
+
+	return b.String()
+}
+
+// TOC scans the Gemini text from r and returns the table of contents of its
+// headings.  The IDs are the same as those given to the headings by an
+// HTMLRenderer with HeadingIDs set.
+func TOC(r io.Reader) ([]Heading, error) {
+	var c Contents
+
+	s := NewScanner(r)
+	for s.Scan() {
+		switch s.Type() { // nolint: exhaustive // only want headings
+		case Head1:
+			c.Add(1, s.Text())
+		case Head2:
+			c.Add(2, s.Text())
+		case Head3:
+			c.Add(3, s.Text())
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning for headings: %w", err)
+	}
+
+	return c.Headings, nil
+}
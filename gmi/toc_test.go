@@ -0,0 +1,90 @@
+package gmi_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+func TestTOC(t *testing.T) {
+	input := "# Title\nText\n## One\n### One A\n### One B\n## Two\n" +
+		"```\n## Not a heading\n```\n### Two A\n# Other\n## One\n"
+
+	toc, err := gmi.TOC(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"1. Title#title",
+		"1.1. One#one",
+		"1.1.1. One A#one-a",
+		"1.1.2. One B#one-b",
+		"1.2. Two#two",
+		"1.2.1. Two A#two-a",
+		"2. Other#other",
+		"2.1. One#one-1",
+	}
+
+	actual := make([]string, 0, len(toc))
+	for _, h := range toc {
+		actual = append(actual, h.String()+"#"+h.ID)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("TOC gave: %q, expecting: %q", actual, expected)
+	}
+}
+
+func TestContentsAdd(t *testing.T) {
+	var c gmi.Contents
+
+	tbls := []struct {
+		level    int
+		text     string
+		expected gmi.Heading
+	}{
+		{2, " Skipped ", gmi.Heading{2, "Skipped", [3]int{0, 1, 0}, "skipped"}},
+		{5, "Deep", gmi.Heading{3, "Deep", [3]int{0, 1, 1}, "deep"}},
+		{0, "Top", gmi.Heading{1, "Top", [3]int{1, 0, 0}, "top"}},
+		{1, "Top", gmi.Heading{1, "Top", [3]int{2, 0, 0}, "top-1"}},
+	}
+
+	for _, tbl := range tbls {
+		if h := c.Add(tbl.level, tbl.text); h != tbl.expected {
+			t.Errorf("Add(%d, %q) gave: %+v, expecting: %+v",
+				tbl.level, tbl.text, h, tbl.expected)
+		}
+	}
+
+	if len(c.Headings) != len(tbls) {
+		t.Errorf("expected %d headings, got: %d", len(tbls), len(c.Headings))
+	}
+
+	if n := c.Headings[0].Numbered(); n != "0.1." {
+		t.Errorf("Numbered gave: %q, expecting: %q", n, "0.1.")
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tbls := []struct {
+		input    string
+		expected string
+	}{
+		{"Title", "title"},
+		{"  Hello,   World!  ", "hello-world"},
+		{"Über café 2", "über-café-2"},
+		{"snake_case-and-dashes", "snake-case-and-dashes"},
+		{"!?", "section"},
+		{"", "section"},
+	}
+
+	for _, tbl := range tbls {
+		if slug := gmi.Slug(tbl.input); slug != tbl.expected {
+			t.Errorf("Slug(%q) gave: %q, expecting: %q",
+				tbl.input, slug, tbl.expected)
+		}
+	}
+}
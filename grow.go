@@ -0,0 +1,195 @@
+package gdn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Errors are the errors that occurred while growing a tree, in the order of the
+// leaves in the tree.
+type Errors []error
+
+// Error returns the messages of all the errors, one per line.
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Is reports whether any of the errors matches the target, so errors.Is can be
+// used on Errors.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first of the errors that matches the target, so errors.As can be
+// used on Errors.
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// err returns nil when there are no errors, the error itself when there is only
+// one, and the Errors otherwise.
+func (e Errors) err() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	default:
+		return e
+	}
+}
+
+// branches returns the branch and all of its sub-branches, with each branch
+// before its sub-branches.
+func (b *Branch) branches() []*Branch {
+	all := []*Branch{b}
+	for _, branch := range b.Branches {
+		all = append(all, branch.branches()...)
+	}
+
+	return all
+}
+
+// grow generates the site from the branch using the shared grower.  The
+// directories are made first, then the leaves are grown by the workers, and
+// last the index pages are generated.
+func (b Branch) grow(g *grower) error {
+	branches := b.branches()
+
+	for _, branch := range branches {
+		if err := branch.mkdirs(g); err != nil {
+			return err
+		}
+	}
+
+	var leaves []*Leaf
+
+	b.Walk(func(l *Leaf) error { // nolint: errcheck // never errors
+		leaves = append(leaves, l)
+		return nil
+	})
+
+	errs := growLeaves(g, leaves)
+
+	for _, branch := range branches {
+		if g.ctx.Err() != nil {
+			break
+		}
+
+		if err := branch.growIndex(g); err != nil {
+			errs = append(errs, err)
+		}
+
+		if g.next != nil {
+			g.next.Branches[branch.Path] = branch.entry(g)
+		}
+
+		if g.capsule == nil {
+			continue
+		}
+
+		if err := branch.growCapsuleIndex(g); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if g.feedSize > 0 && g.ctx.Err() == nil {
+		if err := b.growFeeds(g); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := g.ctx.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("growing stopped: %w", err))
+	}
+
+	return errs.err()
+}
+
+// mkdirs makes the directories for the branch in the destination and the
+// capsule.
+func (b Branch) mkdirs(g *grower) error {
+	if err := os.MkdirAll(b.Dst, BranchPerm); err != nil {
+		return fmt.Errorf("error making directory: %s: %w", b.Dst, err)
+	}
+
+	if g.capsule != nil {
+		dir := filepath.Join(g.capsule.dir, b.Path)
+		if err := os.MkdirAll(dir, BranchPerm); err != nil {
+			return fmt.Errorf("error making directory: %s: %w", dir, err)
+		}
+	}
+
+	return nil
+}
+
+// growLeaves grows the leaves with a pool of workers, until the context of the
+// grower is done.  The errors are returned in the order of the leaves.
+func growLeaves(g *grower, leaves []*Leaf) Errors {
+	workers := g.workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	failed := make([]error, len(leaves))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				failed[i] = leaves[i].grow(g)
+			}
+		}()
+	}
+
+	for i := range leaves {
+		if g.ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case jobs <- i:
+		case <-g.ctx.Done():
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	var errs Errors
+
+	for _, err := range failed {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
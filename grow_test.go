@@ -0,0 +1,121 @@
+package gdn_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestGrowWorkers(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	t.Log("+test that growing with workers matches testdata/expected")
+
+	root := gdn.NewTree(testsrc, tmp)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	if err := root.GrowWith(gdn.GrowOptions{Workers: 4}); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	matchDir(t, tmp, "testdata/expected")
+}
+
+func TestGrowErrors(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		writeFile(t, filepath.Join(src, name), name)
+	}
+
+	root := gdn.NewTree(src, dst)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	t.Log("-test every leaf is grown and all errors are returned in order")
+	t.Log("the index, which lists the missing leaves, fails last")
+
+	for _, name := range []string{"d.txt", "b.txt"} {
+		if err := os.Remove(filepath.Join(src, name)); err != nil {
+			t.Fatalf("could not remove %s: %v", name, err)
+		}
+	}
+
+	err := root.GrowWith(gdn.GrowOptions{Workers: 3})
+
+	var errs gdn.Errors
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("expected three errors, got: %v", err)
+	}
+
+	if !strings.Contains(errs[0].Error(), "b.txt") ||
+		!strings.Contains(errs[1].Error(), "d.txt") {
+		t.Errorf("expected errors for b.txt then d.txt, got: %v", errs)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected errors to match os.ErrNotExist, got: %v", err)
+	}
+
+	pathIsRegularFile(t, filepath.Join(dst, "a.txt"))
+	pathIsRegularFile(t, filepath.Join(dst, "c.txt"))
+}
+
+func TestGrowContext(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	root := gdn.NewTree(testsrc, tmp)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	t.Log("-test growing stops when the context is canceled")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := root.GrowContext(ctx, gdn.GrowOptions{Workers: 2})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmp, "example", "mydoc.html")); !os.
+		IsNotExist(err) {
+		t.Errorf("expected no leaves to be grown, got: %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	errA := errors.New("a")
+	errB := errors.New("b")
+	errs := gdn.Errors{errA, errB}
+
+	if errs.Error() != "a\nb" {
+		t.Errorf("Errors.Error() gave: %q, expecting: %q", errs.Error(), "a\nb")
+	}
+
+	if !errors.Is(errs, errB) {
+		t.Error("expected Errors to match an error it holds")
+	}
+
+	if errors.Is(errs, os.ErrNotExist) {
+		t.Error("expected Errors not to match an error it does not hold")
+	}
+}
@@ -0,0 +1,160 @@
+package gdn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is the name of the file in the root of the garden listing the
+// files and directories to leave out of the tree.
+const IgnoreFile = ".gdnignore"
+
+// Ignore is a list of patterns of files and directories to leave out of the
+// tree, written with the same syntax as a .gitignore file:
+//
+//     # notes that are not ready
+//     *.draft.gmi
+//     /journal/
+//     !journal/index.gmi
+//
+// Blank lines and lines starting with # are skipped.  A pattern ending in /
+// only matches directories.  A pattern with a / at the start or in the middle
+// matches paths from the root of the garden, otherwise it matches a file or
+// directory of that name anywhere.  Patterns may use the wildcards of
+// path.Match along with ** to match any number of directories.  A pattern
+// starting with ! includes what was left out by an earlier pattern, unless a
+// directory it is within is left out.  The last pattern to match wins.
+type Ignore struct {
+	patterns []ignorePattern
+}
+
+// ignorePattern is a single line of an Ignore.
+type ignorePattern struct {
+	segments []string // the pattern split on /
+	negate   bool     // whether the pattern starts with !
+	dirOnly  bool     // whether the pattern ends with /
+}
+
+// LoadIgnore loads the IgnoreFile of the garden rooted at the given source
+// directory.  An empty Ignore is returned when the garden does not have one.
+func LoadIgnore(src string) (*Ignore, error) {
+	file := filepath.Join(src, IgnoreFile)
+
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Ignore{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", file, err)
+	}
+	defer f.Close()
+
+	ig, err := ParseIgnore(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", file, err)
+	}
+
+	return ig, nil
+}
+
+// ParseIgnore parses the patterns of an Ignore, one per line.
+func ParseIgnore(r io.Reader) (*Ignore, error) {
+	ig := &Ignore{}
+	s := bufio.NewScanner(r)
+
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var p ignorePattern
+
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:] // escapes a leading # or !
+		}
+
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+
+		p.segments = strings.Split(line, "/")
+		ig.patterns = append(ig.patterns, p)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("could not read ignore patterns: %w", err)
+	}
+
+	return ig, nil
+}
+
+// Match returns whether the file or directory at the path is left out.  The
+// path is slash separated and relative to the root of the garden, such as
+// /notes/draft.gmi.
+func (ig *Ignore) Match(name string, isDir bool) bool {
+	if ig == nil {
+		return false
+	}
+
+	segments := strings.Split(strings.Trim(path.Clean(name), "/"), "/")
+	ignored := false
+
+	for _, p := range ig.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		if matchSegments(p.segments, segments) {
+			ignored = !p.negate
+		}
+	}
+
+	return ignored
+}
+
+// matchSegments returns whether the segments of a path match the segments of a
+// pattern, where a ** segment matches any number of segments.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+
+		pattern, segments = pattern[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
@@ -0,0 +1,129 @@
+package gdn_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestIgnoreMatch(t *testing.T) {
+	ig, err := gdn.ParseIgnore(strings.NewReader(`# comment
+*.draft.gmi
+/journal/
+!journal/
+private/
+docs/**/old.md
+/top.md
+\#hash.gmi
+
+`))
+	if err != nil {
+		t.Fatalf("parse encountered an unexpected error: %v", err)
+	}
+
+	tbls := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"/a.draft.gmi", false, true},
+		{"/notes/a.draft.gmi", false, true},
+		{"/a.gmi", false, false},
+		{"/journal", true, false}, // included again by !journal/
+		{"/private", true, true},
+		{"/notes/private", true, true},
+		{"/notes/private", false, false},
+		{"/docs/old.md", false, true},
+		{"/docs/a/b/old.md", false, true},
+		{"/other/old.md", false, false},
+		{"/top.md", false, true},
+		{"/notes/top.md", false, false},
+		{"/#hash.gmi", false, true},
+		{"/comment", false, false},
+	}
+
+	for _, tbl := range tbls {
+		if actual := ig.Match(tbl.path, tbl.isDir); actual != tbl.expected {
+			t.Errorf("%s (dir: %v) gave: %v, expecting: %v",
+				tbl.path, tbl.isDir, actual, tbl.expected)
+		}
+	}
+
+	var none *gdn.Ignore
+	if none.Match("/a.gmi", false) {
+		t.Errorf("a nil Ignore should not match anything")
+	}
+}
+
+func TestScanExcludes(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	writeFile(t, filepath.Join(tmp, gdn.IgnoreFile), "/secret/\n*.bak\n")
+	writeFile(t, filepath.Join(tmp, "index.gmi"), "# Home\n")
+	writeFile(t, filepath.Join(tmp, "draft.gmi"), "---\ndraft: true\n---\n")
+	writeFile(t, filepath.Join(tmp, "private.md"), "private: yes\n\nText\n")
+	writeFile(t, filepath.Join(tmp, "page.gmi.bak"), "old")
+	writeFile(t, filepath.Join(tmp, "secret", "page.gmi"), "# Secret\n")
+	writeFile(t, filepath.Join(tmp, "drafts", "draft.gmi"), "draft: true\n\n")
+	writeFile(t, filepath.Join(tmp, gdn.ConfigFile), "{}")
+
+	// Grown into the garden by an earlier build.
+	writeFile(t, filepath.Join(tmp, "dist", "index.html"), "<h1>Home</h1>")
+	writeFile(t, filepath.Join(tmp, "capsule", "index.gmi"), "# Home\n")
+
+	tbls := []struct {
+		name     string
+		opts     gdn.ScanOptions
+		expected []string
+	}{
+		{
+			"without drafts",
+			gdn.ScanOptions{},
+			[]string{"/index.gmi", "/capsule/index.gmi"},
+		},
+		{
+			"with drafts",
+			gdn.ScanOptions{Drafts: true},
+			[]string{
+				"/draft.gmi", "/index.gmi", "/private.md", "/capsule/index.gmi",
+				"/drafts/draft.gmi",
+			},
+		},
+		{
+			"excluding the capsule",
+			gdn.ScanOptions{
+				Exclude: []string{"", filepath.Join(tmp, "capsule")},
+			},
+			[]string{"/index.gmi"},
+		},
+	}
+
+	for _, tbl := range tbls {
+		root := gdn.NewTree(tmp, filepath.Join(tmp, "dist"))
+
+		if err := root.ScanWith(tbl.opts); err != nil {
+			t.Fatalf("%s: scan encountered an unexpected error: %v",
+				tbl.name, err)
+		}
+
+		var paths []string
+
+		err := root.Walk(func(l *gdn.Leaf) error {
+			paths = append(paths, filepath.ToSlash(l.Path))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: walk encountered an unexpected error: %v",
+				tbl.name, err)
+		}
+
+		if !equalStrings(paths, tbl.expected) {
+			t.Errorf("%s: scan gave: %v, expecting: %v",
+				tbl.name, paths, tbl.expected)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package gdn
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IndexFile is the name of the template within the ConfigDir used to list the
+// contents of a directory that has no index page.  The listing is then wrapped
+// in the layout like any other page.
+const IndexFile = "index.html"
+
+// DefaultIndex is the index template used when the garden does not provide an
+// index template of its own.
+const DefaultIndex = `<h1>{{.Title}}</h1>
+{{with .Branches}}<ul>
+{{range .}}<li><a href="{{.URL}}">{{.Name}}/</a></li>
+{{end}}</ul>
+{{end}}{{with .Leaves}}<ul>
+{{range .}}<li><a href="{{.URL}}">{{.Name}}</a></li>
+{{end}}</ul>
+{{end}}`
+
+// defaultIndex is the parsed DefaultIndex.
+var defaultIndex = template.Must( // nolint: gochecknoglobals
+	template.New(IndexFile).Parse(DefaultIndex))
+
+// LoadIndex loads the index template of the garden rooted at the given source
+// directory.  The DefaultIndex is returned when the garden does not have an
+// index template.
+func LoadIndex(src string) (*template.Template, error) {
+	return loadTemplate(src, IndexFile, defaultIndex)
+}
+
+// ErrUnknownSort occurs when decoding an IndexSort with an unknown name.
+var ErrUnknownSort = errors.New("unknown sort")
+
+// IndexSort is the order of the entries listed in a generated index page.
+type IndexSort int
+
+const (
+	// SortByName sorts entries by their name, ignoring case.  This is the
+	// default.
+	SortByName IndexSort = iota
+	// SortByPath sorts entries by their URL path.
+	SortByPath
+	// SortByModified sorts entries by their modification time, newest first.
+	SortByModified
+)
+
+// String returns the string representation of the IndexSort.  For example, for
+// SortByModified it will return the string "modified".
+func (s IndexSort) String() string {
+	switch s {
+	case SortByName:
+		return "name"
+	case SortByPath:
+		return "path"
+	case SortByModified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the IndexSort as its name.
+func (s IndexSort) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes the IndexSort from its name, such as "modified".
+func (s *IndexSort) UnmarshalText(text []byte) error {
+	for _, by := range []IndexSort{SortByName, SortByPath, SortByModified} {
+		if by.String() == string(text) {
+			*s = by
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q", ErrUnknownSort, text)
+}
+
+// Index is the data given to the index template for a directory without an
+// index page.
+type Index struct {
+	// Title is the name of the directory, or "Home" for the root.
+	Title string
+	// Path is the URL path of the directory, ending in a slash.
+	Path string
+	// Branches are the sub-directories of the directory.
+	Branches []Entry
+	// Leaves are the pages and other files in the directory.  Pages are named
+	// by their title.
+	Leaves []Entry
+	// Modified is the time the directory was last modified.
+	Modified time.Time
+}
+
+// Entry is an item listed in an index.
+type Entry struct {
+	Name     string
+	URL      string
+	Modified time.Time
+	Meta     Meta // metadata of a page, nil for everything else
+}
+
+// URL is the path of the branch's directory within the generated site.  It is
+// always slash separated and ends in a slash (e.g. /notes/).
+func (b Branch) URL() string {
+	u := filepath.ToSlash(b.Path)
+	if !strings.HasSuffix(u, "/") {
+		u += "/"
+	}
+
+	return u
+}
+
+// hasLeaf returns whether the branch has a leaf at the given URL, using the
+// link function to get the URL of each leaf.
+func (b Branch) hasLeaf(u string, link func(*Leaf) string) bool {
+	for _, l := range b.Leaves {
+		if link(l) == u {
+			return true
+		}
+	}
+
+	return false
+}
+
+// index returns the data for the index template of the branch.  The link
+// function gives the URL of each leaf within the output.
+func (b Branch) index(g *grower, link func(*Leaf) string) (Index, error) {
+	info, err := os.Stat(b.Src)
+	if err != nil {
+		return Index{}, fmt.Errorf("error getting info for %s: %w", b.Src, err)
+	}
+
+	idx := Index{Path: b.URL(), Modified: info.ModTime()}
+
+	idx.Title = path.Base(b.URL())
+	if idx.Title == "/" {
+		idx.Title = "Home"
+	}
+
+	for _, branch := range b.Branches {
+		info, err := os.Stat(branch.Src)
+		if err != nil {
+			return Index{}, fmt.Errorf("error getting info for %s: %w",
+				branch.Src, err)
+		}
+
+		idx.Branches = append(idx.Branches, Entry{
+			Name:     path.Base(branch.URL()),
+			URL:      branch.URL(),
+			Modified: info.ModTime(),
+		})
+	}
+
+	for _, l := range b.Leaves {
+		info, err := os.Stat(l.Src)
+		if err != nil {
+			return Index{}, fmt.Errorf("error getting info for %s: %w",
+				l.Src, err)
+		}
+
+		name, ok := g.titles[l.Path]
+		if !ok {
+			name = filepath.Base(l.Path)
+		}
+
+		idx.Leaves = append(idx.Leaves, Entry{
+			Name:     name,
+			URL:      link(l),
+			Modified: info.ModTime(),
+			Meta:     l.Meta,
+		})
+	}
+
+	sortEntries(idx.Branches, g.sort)
+	sortEntries(idx.Leaves, g.sort)
+
+	return idx, nil
+}
+
+// sortEntries sorts the entries in the given order.  Entries that are equal in
+// that order are sorted by their URL.
+func sortEntries(entries []Entry, by IndexSort) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+
+		switch by {
+		case SortByName:
+			an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name)
+			if an != bn {
+				return an < bn
+			}
+		case SortByModified:
+			if !a.Modified.Equal(b.Modified) {
+				return a.Modified.After(b.Modified)
+			}
+		case SortByPath:
+		}
+
+		return a.URL < b.URL
+	})
+}
+
+// growIndex generates an index page listing the contents of the branch, unless
+// the branch already has an index page.
+func (b Branch) growIndex(g *grower) error {
+	if b.hasLeaf(path.Join(b.URL(), IndexFile), (*Leaf).URL) {
+		return nil
+	}
+
+	idx, err := b.index(g, (*Leaf).URL)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if err := g.index.Execute(&body, idx); err != nil {
+		return fmt.Errorf("error applying index to %s: %w", b.Src, err)
+	}
+
+	page := Page{
+		Title:       idx.Title,
+		Body:        template.HTML(body.String()), // nolint: gosec // template
+		Path:        path.Join(idx.Path, IndexFile),
+		Breadcrumbs: Breadcrumbs(path.Clean(idx.Path)),
+		Modified:    idx.Modified,
+		Site:        g.site,
+	}
+
+	return writePage(g.layout, page, filepath.Join(b.Dst, IndexFile))
+}
@@ -0,0 +1,127 @@
+package gdn_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestGrowIndex(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.LayoutFile),
+		"{{.Body}}")
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.IndexFile),
+		"{{range .Branches}}{{.Name}} {{end}}|"+
+			"{{range .Leaves}}{{.Name}}={{.URL}} {{end}}")
+	writeFile(t, filepath.Join(src, "index.gmi"), "# Home Page\n")
+	writeFile(t, filepath.Join(src, "notes", "b.gmi"), "# apple\n")
+	writeFile(t, filepath.Join(src, "notes", "a.md"), "# Cherry\n")
+	writeFile(t, filepath.Join(src, "notes", "c.txt"), "")
+	writeFile(t, filepath.Join(src, "notes", "sub", "x.gmi"), "# X\n")
+
+	// Make the modification times predictable for sorting by them.
+	now := time.Now()
+	for i, name := range []string{"b.gmi", "a.md", "c.txt"} {
+		mod := now.Add(time.Duration(i) * time.Hour)
+		file := filepath.Join(src, "notes", name)
+
+		if err := os.Chtimes(file, mod, mod); err != nil {
+			t.Fatalf("could not change times of %s: %v", file, err)
+		}
+	}
+
+	root := gdn.NewTree(src, dst)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	tbls := []struct {
+		sort     gdn.IndexSort
+		expected string
+	}{
+		{
+			gdn.SortByName,
+			"sub |apple=/notes/b.html c.txt=/notes/c.txt " +
+				"Cherry=/notes/a.html ",
+		},
+		{
+			gdn.SortByPath,
+			"sub |Cherry=/notes/a.html apple=/notes/b.html " +
+				"c.txt=/notes/c.txt ",
+		},
+		{
+			gdn.SortByModified,
+			"sub |c.txt=/notes/c.txt Cherry=/notes/a.html " +
+				"apple=/notes/b.html ",
+		},
+	}
+
+	for _, tbl := range tbls {
+		t.Logf("+test an index sorted by %s", tbl.sort)
+
+		err := root.GrowWith(gdn.GrowOptions{IndexSort: tbl.sort})
+		if err != nil {
+			t.Fatalf("grow encountered an unexpected error: %v", err)
+		}
+
+		index := readFile(t, filepath.Join(dst, "notes", "index.html"))
+		if index != tbl.expected {
+			t.Errorf("index gave: %q, expecting: %q", index, tbl.expected)
+		}
+	}
+
+	t.Log("+test an existing index page is not replaced")
+
+	if index := readFile(t, filepath.Join(dst, "index.html")); index !=
+		"<h1 id=\"home-page\">Home Page</h1>\n" {
+		t.Errorf("index page was replaced with: %q", index)
+	}
+}
+
+func TestIndexSortString(t *testing.T) {
+	tbls := []struct {
+		sort     gdn.IndexSort
+		expected string
+	}{
+		{gdn.SortByName, "name"},
+		{gdn.SortByPath, "path"},
+		{gdn.SortByModified, "modified"},
+		{gdn.IndexSort(256), "unknown"},
+	}
+
+	for _, tbl := range tbls {
+		result := tbl.sort.String()
+		if result != tbl.expected {
+			t.Errorf("IndexSort(%d).String() gave: %s, expecting: %s",
+				tbl.sort, result, tbl.expected)
+		}
+	}
+}
+
+func TestBranchURL(t *testing.T) {
+	tbls := []struct {
+		branch   gdn.Branch
+		expected string
+	}{
+		{gdn.Branch{Path: "/"}, "/"},
+		{gdn.Branch{Path: "/notes"}, "/notes/"},
+		{gdn.Branch{Path: "/notes/sub"}, "/notes/sub/"},
+	}
+
+	for _, tbl := range tbls {
+		result := tbl.branch.URL()
+		if result != tbl.expected {
+			t.Errorf("Branch %+v .URL() gave: %s, expecting: %s",
+				tbl.branch, result, tbl.expected)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package gdn
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+// ConfigDir is the directory in the root of the garden that holds files to
+// configure how the garden grows, such as the layout.  Being a hidden
+// directory, it is never scanned as part of the tree.
+const ConfigDir = ".gdn"
+
+// LayoutFile is the name of the layout template within the ConfigDir.
+const LayoutFile = "layout.html"
+
+// DefaultLayout is the layout used to wrap pages when the garden does not
+// provide a layout of its own.
+const DefaultLayout = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+</head>
+<body>
+<nav>{{range .Breadcrumbs}}<a href="{{.URL}}">{{.Name}}</a> / {{end}}</nav>
+{{with .TOC}}<nav class="toc">
+<ul>
+{{range .}}<li class="toc-{{.Level}}"><a href="#{{.ID}}">{{.}}</a></li>
+{{end}}</ul>
+</nav>
+{{end}}<main>
+{{.Body}}</main>
+{{with .Backlinks}}<aside>
+<h2>Linked from</h2>
+<ul>
+{{range .}}<li><a href="{{.URL}}">{{.Name}}</a></li>
+{{end}}</ul>
+</aside>
+{{end}}</body>
+</html>
+`
+
+// defaultLayout is the parsed DefaultLayout.
+var defaultLayout = template.Must( // nolint: gochecknoglobals
+	template.New(LayoutFile).Parse(DefaultLayout))
+
+// Page is the data given to the layout template when a page is rendered.
+type Page struct {
+	// Title is the text of the first level 1 heading of the page.  If the page
+	// has no such heading, it is the file name without its extension.
+	Title string
+	// Body is the rendered HTML of the page.  For the Gemini layout of a
+	// capsule, it is the Gemini text of the page instead.
+	Body template.HTML
+	// Path is the URL path of the page within the site (e.g. /notes/a.html).
+	Path string
+	// Breadcrumbs link to the directories leading up to the page, starting
+	// with the root of the site.
+	Breadcrumbs []Crumb
+	// Modified is the time the source of the page was last modified.
+	Modified time.Time
+	// Backlinks link to the pages that link to the page, sorted by their path.
+	Backlinks []Crumb
+	// Meta is the metadata from the top of the page, if it has any.
+	Meta Meta
+	// TOC is the table of contents of the page, listing its headings along
+	// with their IDs.  It is only set when the table of contents is turned on
+	// for the page by the toc key of its metadata, or for the whole site.
+	TOC []gmi.Heading
+	// Site is the title, URL and author of the site the page is in.
+	Site Site
+}
+
+// Site describes the site as a whole.  Each field is empty unless it is set
+// in the GrowOptions, such as from the ConfigFile.
+type Site struct {
+	Title  string
+	URL    string // the URL the site is published at
+	Author string
+}
+
+// Crumb is a link to a page or a directory along with the name to show for
+// it.
+type Crumb struct {
+	Name string
+	URL  string
+}
+
+// LoadLayout loads the layout template of the garden rooted at the given
+// source directory.  The DefaultLayout is returned when the garden does not
+// have a layout file.
+func LoadLayout(src string) (*template.Template, error) {
+	return loadTemplate(src, LayoutFile, defaultLayout)
+}
+
+// loadTemplate loads the named template from the ConfigDir of the garden rooted
+// at the given source directory.  The fallback is returned when the garden does
+// not have the template.
+func loadTemplate(src, name string,
+	fallback *template.Template) (*template.Template, error) {
+	file := filepath.Join(src, ConfigDir, name)
+
+	tmpl, err := template.ParseFiles(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return fallback, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not load template: %s: %w", file, err)
+	}
+
+	return tmpl, nil
+}
+
+// Breadcrumbs returns the crumbs for the directories leading up to the given
+// URL path.  The first crumb is always the root of the site.
+func Breadcrumbs(urlPath string) []Crumb {
+	crumbs := []Crumb{{Name: "Home", URL: "/"}}
+	dir := "/"
+
+	for _, name := range strings.Split(path.Dir(urlPath), "/") {
+		if name == "" {
+			continue
+		}
+
+		dir = path.Join(dir, name) + "/"
+		crumbs = append(crumbs, Crumb{Name: name, URL: dir})
+	}
+
+	return crumbs
+}
+
+// renderPage wraps the rendered page of the leaf in the layout and writes it to
+// the leaf's destination.  The table of contents is included when it is turned
+// on for the page.
+func (l Leaf) renderPage(g *grower, r Rendered) error {
+	page, err := l.page(g, r.Title, (*Leaf).URL)
+	if err != nil {
+		return err
+	}
+
+	page.Body = template.HTML(r.Body) // nolint: gosec // rendered by us
+
+	if l.showTOC(g) {
+		page.TOC = r.TOC
+	}
+
+	return writePage(g.layout, page, l.Dst())
+}
+
+// showTOC returns whether the table of contents is shown on the page.  The toc
+// key in the page's metadata overrides the setting for the site.
+func (l Leaf) showTOC(g *grower) bool {
+	if _, ok := l.Meta["toc"]; ok {
+		return l.Meta.Bool("toc")
+	}
+
+	return g.toc
+}
+
+// executor is a template that can be executed, either from html/template or
+// text/template.
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// writePage wraps the page in the layout and writes it to the destination.
+func writePage(layout executor, page Page, dst string) error {
+	var buf bytes.Buffer
+	if err := layout.Execute(&buf, page); err != nil {
+		return fmt.Errorf("error applying layout to %s: %w", page.Path, err)
+	}
+
+	if err := ioutil.WriteFile(dst, buf.Bytes(), LeafPerm); err != nil {
+		return fmt.Errorf("error writing %s: %w", dst, err)
+	}
+
+	return nil
+}
+
+// page returns the data for the layout of the leaf, leaving the body for the
+// caller to set.  The link function gives the URL of a leaf within the output,
+// which is used for the page itself and the leaves linking to it.
+func (l Leaf) page(g *grower, title string,
+	link func(*Leaf) string) (Page, error) {
+	info, err := os.Stat(l.Src)
+	if err != nil {
+		return Page{}, fmt.Errorf("error getting info for %s: %w", l.Src, err)
+	}
+
+	backlinks := g.graph.Backlinks(l.Path)
+	crumbs := make([]Crumb, 0, len(backlinks))
+
+	for _, from := range backlinks {
+		crumbs = append(crumbs,
+			Crumb{Name: g.titles[from.Path], URL: link(from)})
+	}
+
+	return Page{
+		Title:       title,
+		Path:        link(&l),
+		Breadcrumbs: Breadcrumbs(link(&l)),
+		Modified:    info.ModTime(),
+		Backlinks:   crumbs,
+		Meta:        l.Meta,
+		Site:        g.site,
+	}, nil
+}
@@ -0,0 +1,171 @@
+package gdn_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+// writeFile writes the contents to the path, making any parent directories.
+// Calls t.Fatalf() if an error occurs.
+func writeFile(t *testing.T, path, contents string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		t.Fatalf("could not make directory for %s: %v", path, err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("could not write %s: %v", path, err)
+	}
+}
+
+// readFile reads the contents of the path as a string.
+// Calls t.Fatalf() if an error occurs.
+func readFile(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read %s: %v", path, err)
+	}
+
+	return string(b)
+}
+
+func TestGrowWithLayout(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.LayoutFile),
+		"<title>{{.Title}}</title>{{.Path}}|"+
+			"{{range .Breadcrumbs}}{{.Name}}:{{.URL}},{{end}}|{{.Body}}")
+	writeFile(t, filepath.Join(src, "notes", "page.gmi"),
+		"## Sub\n# Page <Title>\n")
+	writeFile(t, filepath.Join(src, "notes", "untitled.md"), "Text\n")
+
+	root := gdn.NewTree(src, dst)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	if err := root.Grow(); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	t.Log("+test the layout receives the page data")
+
+	expected := "<title>Page &lt;Title&gt;</title>/notes/page.html|" +
+		"Home:/,notes:/notes/,|" +
+		"<h2 id=\"sub\">Sub</h2>\n" +
+		"<h1 id=\"page-title\">Page &lt;Title&gt;</h1>\n"
+	if page := readFile(t, filepath.Join(dst, "notes", "page.html")); page !=
+		expected {
+		t.Errorf("page gave: %q, expecting: %q", page, expected)
+	}
+
+	t.Log("+test the title falls back to the file name")
+
+	expected = "<title>untitled</title>/notes/untitled.html|" +
+		"Home:/,notes:/notes/,|<p>Text</p>\n"
+	page := readFile(t, filepath.Join(dst, "notes", "untitled.html"))
+	if page != expected {
+		t.Errorf("page gave: %q, expecting: %q", page, expected)
+	}
+
+	t.Log("-test a layout that does not parse")
+
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.LayoutFile), "{{")
+
+	if err := root.Grow(); err == nil {
+		t.Error("expected an error when the layout does not parse")
+	}
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	tbls := []struct {
+		path     string
+		expected []gdn.Crumb
+	}{
+		{"/index.html", []gdn.Crumb{{Name: "Home", URL: "/"}}},
+		{
+			"/a/b/page.html",
+			[]gdn.Crumb{
+				{Name: "Home", URL: "/"},
+				{Name: "a", URL: "/a/"},
+				{Name: "b", URL: "/a/b/"},
+			},
+		},
+	}
+
+	for _, tbl := range tbls {
+		result := gdn.Breadcrumbs(tbl.path)
+		if !reflect.DeepEqual(result, tbl.expected) {
+			t.Errorf("Breadcrumbs(%s) gave: %+v, expecting: %+v",
+				tbl.path, result, tbl.expected)
+		}
+	}
+}
+
+func TestGrowWithTOC(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.LayoutFile),
+		"{{range .TOC}}{{.Numbered}}{{.ID}},{{end}}")
+	writeFile(t, filepath.Join(src, "on.gmi"), "toc: on\n\n# A\n## B\n## B\n")
+	writeFile(t, filepath.Join(src, "off.md"), "toc: off\n\n# A\n")
+	writeFile(t, filepath.Join(src, "site.md"), "# A\n\n## B {#custom}\n")
+
+	root := gdn.NewTree(src, dst)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	tbls := []struct {
+		name     string
+		opts     gdn.GrowOptions
+		expected map[string]string
+	}{
+		{
+			"turned on by the page",
+			gdn.GrowOptions{},
+			map[string]string{
+				"on.html":   "1.a,1.1.b,1.2.b-1,",
+				"off.html":  "",
+				"site.html": "",
+			},
+		},
+		{
+			"turned on for the site",
+			gdn.GrowOptions{TOC: true},
+			map[string]string{
+				"on.html":   "1.a,1.1.b,1.2.b-1,",
+				"off.html":  "",
+				"site.html": "1.a,1.1.custom,",
+			},
+		},
+	}
+
+	for _, tbl := range tbls {
+		if err := root.GrowWith(tbl.opts); err != nil {
+			t.Fatalf("%s: grow encountered an unexpected error: %v",
+				tbl.name, err)
+		}
+
+		for name, expected := range tbl.expected {
+			if page := readFile(t, filepath.Join(dst, name)); page != expected {
+				t.Errorf("%s: %s gave: %q, expecting: %q",
+					tbl.name, name, page, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package gdn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+	"github.com/russross/blackfriday/v2"
+)
+
+// ErrLinkStatus occurs when a link to another site responds with an error.
+var ErrLinkStatus = errors.New("link responded with an error")
+
+// LinkResolver checks a link to another site, returning an error if it is
+// broken.
+type LinkResolver func(ctx context.Context, link *url.URL) error
+
+// CheckLinksOptions are options for checking the links of a tree.
+type CheckLinksOptions struct {
+	// External, if set, checks the links to other sites.  They are skipped
+	// otherwise.
+	External LinkResolver
+}
+
+// BrokenLinksError is returned when growing with GrowOptions.Strict finds
+// broken links.
+type BrokenLinksError struct {
+	Links []gmi.Diagnostic
+}
+
+// Error summarizes the broken links, listing each on its own line.
+func (e *BrokenLinksError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "found %d broken link", len(e.Links))
+
+	if len(e.Links) != 1 {
+		b.WriteByte('s')
+	}
+
+	for _, d := range e.Links {
+		fmt.Fprintf(&b, "\n%s", d)
+	}
+
+	return b.String()
+}
+
+// pageLink is a link found in the source of a page.
+type pageLink struct {
+	ref          string // URL of the link, as written
+	line, column int    // where the URL starts, counting from 1
+}
+
+// CheckLinks finds the links in every Gemini and Markdown page of the tree that
+// point to pages or files that are not in the tree, resolving relative links
+// against the Path of the page.  Links to directories of the tree, their index
+// pages and the other files growing generates, such as the feeds, are not
+// broken since they are in the grown site.  Links to other sites are checked
+// with the External resolver if there is one.  The problems are returned as
+// errors in the order of the leaves in the tree, with the File of each being
+// the Src of the leaf.
+func (b Branch) CheckLinks(ctx context.Context,
+	opts CheckLinksOptions) ([]gmi.Diagnostic, error) {
+	leaves := make(map[string]*Leaf)
+	generated := b.generated()
+
+	b.Walk(func(l *Leaf) error { // nolint: errcheck // never errors
+		leaves[filepath.ToSlash(l.Path)] = l
+		leaves[l.URL()] = l
+
+		return nil
+	})
+
+	var diags []gmi.Diagnostic
+
+	err := b.Walk(func(l *Leaf) error {
+		if l.Typ != Markdown && l.Typ != Gemini {
+			return nil
+		}
+
+		src, err := ioutil.ReadFile(l.Src)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", l.Src, err)
+		}
+
+		for _, link := range l.links(src) {
+			msg := checkLink(ctx, leaves, generated, l, link.ref, opts)
+			if msg == "" {
+				continue
+			}
+
+			diags = append(diags, gmi.Diagnostic{
+				File:     l.Src,
+				Line:     link.line,
+				Column:   link.column,
+				Severity: gmi.SeverityError,
+				Message:  msg,
+			})
+		}
+
+		return ctx.Err()
+	})
+
+	return diags, err
+}
+
+// generated returns the URL paths of the directories of the tree and of the
+// files growing it generates, which are not leaves: the index pages of the
+// directories, the feeds and the AssetsFile.
+func (b Branch) generated() map[string]bool {
+	root := path.Clean(b.URL())
+	generated := map[string]bool{
+		path.Join(root, FeedFile):    true,
+		path.Join(root, GemfeedFile): true,
+		path.Join(root, AssetsFile):  true,
+	}
+
+	for _, branch := range b.branches() {
+		dir := path.Clean(branch.URL())
+		generated[dir] = true
+		generated[path.Join(dir, IndexFile)] = true
+		generated[path.Join(dir, GeminiIndexFile)] = true
+	}
+
+	return generated
+}
+
+// checkLink checks a link found in the leaf, returning why it is broken or an
+// empty string if it is not.  Links to the generated paths are never broken.
+func checkLink(ctx context.Context, leaves map[string]*Leaf,
+	generated map[string]bool, from *Leaf, ref string,
+	opts CheckLinksOptions) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "" // left for Lint to report
+	}
+
+	if u.Scheme != "" || u.Host != "" {
+		if opts.External == nil {
+			return ""
+		}
+
+		if err := opts.External(ctx, u); err != nil {
+			return fmt.Sprintf("broken link to %s: %v", ref, err)
+		}
+
+		return ""
+	}
+
+	target, ok := resolveLink(filepath.ToSlash(from.Path), ref)
+	if !ok || generated[target] || lookup(leaves, from.Path, ref) != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("broken link to %s: %s is not in the garden",
+		ref, target)
+}
+
+// links returns the links in the source of the page along with where they are.
+// For Markdown, these are the destinations of links and images.
+func (l Leaf) links(src []byte) []pageLink {
+	if l.Typ == Gemini {
+		return geminiLinks(src)
+	}
+
+	_, body := ParseMeta(src)
+	offset := len(src) - len(body)
+
+	var links []pageLink
+
+	parseMarkdown(body).Walk(func(n *blackfriday.Node,
+		entering bool) blackfriday.WalkStatus {
+		isLink := n.Type == blackfriday.Link || n.Type == blackfriday.Image
+		if !entering || !isLink || len(n.Destination) == 0 {
+			return blackfriday.GoToNext
+		}
+
+		// The parsed Markdown does not know where the link is, so it is
+		// found by looking for the destination after the previous link.
+		link := pageLink{ref: string(n.Destination)}
+
+		if i := bytes.Index(src[offset:], n.Destination); i != -1 {
+			offset += i
+			link.line, link.column = position(src, offset)
+		}
+
+		links = append(links, link)
+
+		return blackfriday.GoToNext
+	})
+
+	return links
+}
+
+// geminiLinks returns the link lines in Gemini text along with where they are.
+func geminiLinks(src []byte) []pageLink {
+	var links []pageLink
+
+	lines := bytes.Split(src, []byte("\n"))
+
+	s := gmi.NewScanner(bytes.NewReader(src))
+	for s.Scan() {
+		if s.Type() != gmi.Link || len(s.URLBytes()) == 0 {
+			continue
+		}
+
+		link := pageLink{ref: s.URL(), line: s.Line(), column: 1}
+
+		line := lines[s.Line()-1]
+		if i := bytes.Index(line, s.URLBytes()); i != -1 {
+			link.column = utf8.RuneCount(line[:i]) + 1
+		}
+
+		links = append(links, link)
+	}
+
+	return links
+}
+
+// position returns the line and column of the byte offset in the source,
+// counting from 1.
+func position(src []byte, offset int) (int, int) {
+	before := src[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+
+	if i := bytes.LastIndexByte(before, '\n'); i != -1 {
+		before = before[i+1:]
+	}
+
+	return line, utf8.RuneCount(before) + 1
+}
+
+// HTTPResolver returns a LinkResolver that checks links to other sites over
+// HTTP or HTTPS with a HEAD request using the client, reporting those that
+// respond with an error status.  Servers that do not allow HEAD requests are
+// asked again with a GET request.  Links with other schemes are not checked.
+// The client should have a Timeout so a server that never responds does not
+// stop the check.
+func HTTPResolver(client *http.Client) LinkResolver {
+	return func(ctx context.Context, link *url.URL) error {
+		if link.Scheme != "http" && link.Scheme != "https" {
+			return nil
+		}
+
+		status, err := request(ctx, client, http.MethodHead, link)
+		if err == nil && (status == http.StatusMethodNotAllowed ||
+			status == http.StatusNotImplemented) {
+			status, err = request(ctx, client, http.MethodGet, link)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if status >= http.StatusBadRequest {
+			return fmt.Errorf("%w: %d %s", ErrLinkStatus, status,
+				http.StatusText(status))
+		}
+
+		return nil
+	}
+}
+
+// request requests the link with the method and returns the status code of
+// the response.  The body of the response is not read.
+func request(ctx context.Context, client *http.Client, method string,
+	link *url.URL) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, link.String(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not make request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("could not request: %w", err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,162 @@
+package gdn_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestCheckLinks(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	writeFile(t, filepath.Join(tmp, "a.gmi"), "# A\n"+
+		"=> b.md B\n"+
+		"=> missing.gmi Missing\n"+
+		"=> /notes/ Notes\n"+
+		"=>  notes\n"+
+		"=> notes/c.html#top C\n"+
+		"=> image.png\n"+
+		"=> #top\n"+
+		"=> gemini://example.com/ Example\n"+
+		"=> https://bad.example/ Bad\n"+
+		"=> /atom.xml Feed\n"+
+		"=> feed.gmi Gemini feed\n"+
+		"=> assets.json\n"+
+		"=> notes/index.html\n"+
+		"=> /notes/index.gmi\n"+
+		"=> notes/missing/index.html\n")
+	writeFile(t, filepath.Join(tmp, "b.md"), "---\ntitle: B\n---\n"+
+		"[A](a.gmi) and [gone](../gone.md)\n\n![image](notes/image.png)\n")
+	writeFile(t, filepath.Join(tmp, "image.png"), "png")
+	writeFile(t, filepath.Join(tmp, "notes", "c.gmi"), "=> ../a.gmi\n")
+
+	root := gdn.NewTree(tmp, filepath.Join(tmp, "dst"))
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	external := func(_ context.Context, u *url.URL) error {
+		if u.Host == "bad.example" {
+			return errors.New("not found") // nolint: goerr113 // test
+		}
+
+		return nil
+	}
+
+	tbls := []struct {
+		name     string
+		opts     gdn.CheckLinksOptions
+		expected []string
+	}{
+		{"internal", gdn.CheckLinksOptions{}, []string{
+			":3:4: error: broken link to missing.gmi: " +
+				"/missing.gmi is not in the garden",
+			":16:4: error: broken link to notes/missing/index.html: " +
+				"/notes/missing/index.html is not in the garden",
+			":4:23: error: broken link to ../gone.md: " +
+				"/gone.md is not in the garden",
+			":6:10: error: broken link to notes/image.png: " +
+				"/notes/image.png is not in the garden",
+		}},
+		{"external", gdn.CheckLinksOptions{External: external}, []string{
+			":3:4: error: broken link to missing.gmi: " +
+				"/missing.gmi is not in the garden",
+			":10:4: error: broken link to https://bad.example/: not found",
+			":16:4: error: broken link to notes/missing/index.html: " +
+				"/notes/missing/index.html is not in the garden",
+			":4:23: error: broken link to ../gone.md: " +
+				"/gone.md is not in the garden",
+			":6:10: error: broken link to notes/image.png: " +
+				"/notes/image.png is not in the garden",
+		}},
+	}
+
+	for _, tbl := range tbls {
+		diags, err := root.CheckLinks(context.Background(), tbl.opts)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tbl.name, err)
+		}
+
+		var actual []string
+		for _, d := range diags {
+			actual = append(actual, strings.TrimPrefix(
+				strings.TrimPrefix(d.String(), filepath.Join(tmp, "a.gmi")),
+				filepath.Join(tmp, "b.md")))
+		}
+
+		if !equalStrings(actual, tbl.expected) {
+			t.Errorf("%s: CheckLinks gave: %q, expecting: %q",
+				tbl.name, actual, tbl.expected)
+		}
+	}
+
+	t.Log("-test growing with strict stops at broken links")
+
+	err := root.GrowWith(gdn.GrowOptions{Strict: true})
+
+	var broken *gdn.BrokenLinksError
+	if !errors.As(err, &broken) || len(broken.Links) != 4 {
+		t.Fatalf("expected 4 broken links, got: %v", err)
+	}
+
+	if !strings.HasPrefix(err.Error(), "found 4 broken links\n") {
+		t.Errorf("error does not summarize the broken links: %q", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmp, "dst")); !os.IsNotExist(err) {
+		t.Errorf("nothing should be grown with broken links: %v", err)
+	}
+}
+
+func TestHTTPResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/get" && r.Method == http.MethodHead:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			case r.URL.Path != "/ok" && r.URL.Path != "/get":
+				http.NotFound(w, r)
+			}
+		}))
+	defer server.Close()
+
+	resolve := gdn.HTTPResolver(server.Client())
+
+	tbls := []struct {
+		link   string
+		broken bool
+	}{
+		{server.URL + "/ok", false},
+		{server.URL + "/missing", true},
+		{server.URL + "/get", false},
+		{"gemini://example.com/", false},
+	}
+
+	for _, tbl := range tbls {
+		u, err := url.Parse(tbl.link)
+		if err != nil {
+			t.Fatalf("could not parse %s: %v", tbl.link, err)
+		}
+
+		err = resolve(context.Background(), u)
+		if broken := err != nil; broken != tbl.broken {
+			t.Errorf("%s: broken gave: %v, expecting: %v (%v)",
+				tbl.link, broken, tbl.broken, err)
+		}
+
+		if tbl.broken && !errors.Is(err, gdn.ErrLinkStatus) {
+			t.Errorf("%s: expected %v, got: %v",
+				tbl.link, gdn.ErrLinkStatus, err)
+		}
+	}
+}
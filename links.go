@@ -0,0 +1,291 @@
+package gdn
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+// LinkGraph holds the links between the leaves of a tree.  Leaves are
+// identified by their Path.
+type LinkGraph struct {
+	links     map[string][]*Leaf // leaves linked to, by the linking leaf
+	backlinks map[string][]*Leaf // leaves linking, by the linked leaf
+}
+
+// Links returns the leaves that the leaf with the given path links to, in the
+// order they are first linked.
+func (g *LinkGraph) Links(path string) []*Leaf {
+	if g == nil {
+		return nil
+	}
+
+	return g.links[filepath.ToSlash(path)]
+}
+
+// Backlinks returns the leaves that link to the leaf with the given path,
+// sorted by their path.
+func (g *LinkGraph) Backlinks(path string) []*Leaf {
+	if g == nil {
+		return nil
+	}
+
+	return g.backlinks[filepath.ToSlash(path)]
+}
+
+// add adds a link between two leaves unless it is already in the graph.  Links
+// from a leaf to itself are ignored.
+func (g *LinkGraph) add(from, to *Leaf) {
+	src, dst := filepath.ToSlash(from.Path), filepath.ToSlash(to.Path)
+	if src == dst {
+		return
+	}
+
+	for _, l := range g.links[src] {
+		if l == to {
+			return
+		}
+	}
+
+	g.links[src] = append(g.links[src], to)
+	g.backlinks[dst] = append(g.backlinks[dst], from)
+}
+
+// LinkGraph reads the link lines of every Gemini leaf in the tree and returns
+// the graph of links between the leaves.  Links to other sites and links to
+// files that are not in the tree are left out.
+func (b Branch) LinkGraph() (*LinkGraph, error) {
+	g, err := survey(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.graph, nil
+}
+
+// Backlinks returns the leaves in the tree that link to the leaf with the given
+// path, sorted by their path.  Use LinkGraph to look up the backlinks of many
+// leaves without reading the tree each time.
+func (b Branch) Backlinks(path string) ([]*Leaf, error) {
+	g, err := b.LinkGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	return g.Backlinks(path), nil
+}
+
+// Walk calls fn for every leaf in the tree.  The leaves of a branch are walked
+// before its sub-branches.  Walking stops at the first error returned by fn.
+func (b Branch) Walk(fn func(*Leaf) error) error {
+	for _, leaf := range b.Leaves {
+		if err := fn(leaf); err != nil {
+			return err
+		}
+	}
+
+	for _, branch := range b.Branches {
+		if err := branch.Walk(fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// survey reads every page in the tree to learn their titles and the links
+// between them.  It returns a grower with the DefaultLayout that holds what
+// was learned.
+func survey(b Branch) (*grower, error) {
+	g := &grower{
+		layout: defaultLayout,
+		titles: make(map[string]string),
+		graph: &LinkGraph{
+			links:     make(map[string][]*Leaf),
+			backlinks: make(map[string][]*Leaf),
+		},
+	}
+
+	leaves := make(map[string]*Leaf)
+	refs := make(map[*Leaf][]string)
+
+	err := b.Walk(func(l *Leaf) error {
+		leaves[filepath.ToSlash(l.Path)] = l
+		leaves[l.URL()] = l
+
+		if !l.Typ.isPage() {
+			return nil
+		}
+
+		src, err := ioutil.ReadFile(l.Src)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", l.Src, err)
+		}
+
+		l.Meta, src = ParseMeta(src)
+
+		title, links, err := l.survey(src)
+		if err != nil {
+			return err
+		}
+
+		g.titles[l.Path] = title
+		refs[l] = links
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for l, links := range refs {
+		for _, ref := range links {
+			if to := lookup(leaves, l.Path, ref); to != nil {
+				g.graph.add(l, to)
+			}
+		}
+	}
+
+	for _, from := range g.graph.backlinks {
+		sort.Slice(from, func(i, j int) bool {
+			return from[i].Path < from[j].Path
+		})
+	}
+
+	return g, nil
+}
+
+// survey returns the title of the page along with the URLs it links to.  Only
+// the links of Gemini pages are followed.  The title of a page that is neither
+// Markdown nor Gemini comes from rendering it.
+func (l Leaf) survey(src []byte) (string, []string, error) {
+	switch l.Typ {
+	case Gemini:
+	case Markdown:
+		return l.titleOr(markdownTitle(src)), nil, nil
+	default:
+		r, err := l.render(src)
+
+		return r.Title, nil, err
+	}
+
+	var (
+		title string
+		links []string
+	)
+
+	s := gmi.NewScanner(bytes.NewReader(src))
+	for s.Scan() {
+		switch s.Type() { // nolint: exhaustive // only want titles and links
+		case gmi.Head1:
+			if title == "" {
+				title = strings.TrimSpace(s.Text())
+			}
+		case gmi.Link:
+			links = append(links, s.URL())
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return "", nil, fmt.Errorf("error reading %s: %w", l.Src, err)
+	}
+
+	return l.titleOr(title), links, nil
+}
+
+// titleOr returns the title from the leaf's metadata, falling back on the given
+// title, then on the file name of the leaf without its extension.
+func (l Leaf) titleOr(title string) string {
+	if t := l.Meta["title"]; t != "" {
+		return t
+	}
+
+	if title == "" {
+		return ChExt(filepath.Base(l.Src), "")
+	}
+
+	return title
+}
+
+// indexNames are the names of pages that are looked up when a link points to a
+// directory.
+var indexNames = []string{ // nolint: gochecknoglobals
+	"index.gmi", "index.gemini", "index.md", "index.html",
+}
+
+// lookup finds the leaf that a link found in the page at the given path points
+// to.  It returns nil when the link goes to another site or to something that
+// is not a leaf.
+func lookup(leaves map[string]*Leaf, from, ref string) *Leaf {
+	target, ok := resolveLink(filepath.ToSlash(from), ref)
+	if !ok {
+		return nil
+	}
+
+	if leaf, ok := leaves[target]; ok {
+		return leaf
+	}
+
+	for _, name := range indexNames {
+		if leaf, ok := leaves[path.Join(target, name)]; ok {
+			return leaf
+		}
+	}
+
+	return nil
+}
+
+// resolveLink resolves a link found in the page at the from path into a path
+// within the site.  Query strings and fragments are dropped.  It returns false
+// for links that go to another site, or that cannot be parsed.
+func resolveLink(from, ref string) (string, bool) {
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+
+	if path.IsAbs(u.Path) {
+		return path.Clean(u.Path), true
+	}
+
+	return path.Join(path.Dir(from), u.Path), true
+}
+
+// RewriteLink rewrites a link to a page in the garden so it points to the HTML
+// page generated from it.  The extension is changed in the same way as
+// Leaf.Dst, so a link to other.gmi becomes a link to other.html.  Query
+// strings and fragments are kept.  Links to other sites, or with a scheme such
+// as gemini:, https: or mailto:, are returned unchanged, as are links to files
+// that are not pages.
+func RewriteLink(ref string) string {
+	return retargetLink(ref, ".html")
+}
+
+// retargetLink changes the extension of a link to a page in the garden to the
+// given extension.  Only the extension is changed, so the rest of the link is
+// kept as it was written.  Links to other sites, or with a scheme, are
+// returned unchanged, as are links to files that are not pages.
+func retargetLink(ref, ext string) string {
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return ref
+	}
+
+	if !TypeByExtension(path.Ext(u.Path)).isPage() {
+		return ref
+	}
+
+	end := strings.IndexAny(ref, "?#")
+	if end < 0 {
+		end = len(ref)
+	}
+
+	return ChExt(ref[:end], ext) + ref[end:]
+}
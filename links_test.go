@@ -0,0 +1,149 @@
+package gdn_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+// leafPaths returns the paths of the leaves.
+func leafPaths(leaves []*gdn.Leaf) []string {
+	paths := make([]string, 0, len(leaves))
+	for _, l := range leaves {
+		paths = append(paths, l.Path)
+	}
+
+	return paths
+}
+
+func TestBranchBacklinks(t *testing.T) {
+	root := gdn.NewTree(testsrc, "tmp")
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	backlinks, err := root.Backlinks("/example/mydoc.md")
+	if err != nil {
+		t.Fatalf("backlinks encountered an unexpected error: %v", err)
+	}
+
+	paths := leafPaths(backlinks)
+	if len(paths) != 1 || paths[0] != "/example/mygemini.gmi" {
+		t.Errorf("backlinks of /example/mydoc.md gave: %v, expecting: %v",
+			paths, []string{"/example/mygemini.gmi"})
+	}
+}
+
+func TestLinkGraph(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	writeFile(t, filepath.Join(tmp, "index.gmi"), `# Home
+=> notes/ Notes
+=> notes/a.gmi#part A
+=> /notes/b.gmi?q=1 B
+=> notes/a.gmi Again
+=> gemini://example.tld/notes/a.gmi External
+=> https://example.tld/ External
+=> mailto:me@example.tld Mail
+=> index.gmi Self
+=> missing.gmi Missing
+`)
+	writeFile(t, filepath.Join(tmp, "notes", "index.gmi"), "=> ../ Up\n")
+	writeFile(t, filepath.Join(tmp, "notes", "a.gmi"), "=> b.gmi B\n")
+	writeFile(t, filepath.Join(tmp, "notes", "b.gmi"), "=> ../image.png\n")
+	writeFile(t, filepath.Join(tmp, "image.png"), "")
+
+	root := gdn.NewTree(tmp, "tmp")
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	graph, err := root.LinkGraph()
+	if err != nil {
+		t.Fatalf("link graph encountered an unexpected error: %v", err)
+	}
+
+	tbls := []struct {
+		path      string
+		links     []string
+		backlinks []string
+	}{
+		{
+			"/index.gmi",
+			[]string{"/notes/index.gmi", "/notes/a.gmi", "/notes/b.gmi"},
+			[]string{"/notes/index.gmi"},
+		},
+		{"/notes/a.gmi", []string{"/notes/b.gmi"}, []string{"/index.gmi"}},
+		{
+			"/notes/b.gmi",
+			[]string{"/image.png"},
+			[]string{"/index.gmi", "/notes/a.gmi"},
+		},
+		{"/image.png", []string{}, []string{"/notes/b.gmi"}},
+	}
+
+	for _, tbl := range tbls {
+		links := leafPaths(graph.Links(tbl.path))
+		if !equalStrings(links, tbl.links) {
+			t.Errorf("links of %s gave: %v, expecting: %v",
+				tbl.path, links, tbl.links)
+		}
+
+		backlinks := leafPaths(graph.Backlinks(tbl.path))
+		if !equalStrings(backlinks, tbl.backlinks) {
+			t.Errorf("backlinks of %s gave: %v, expecting: %v",
+				tbl.path, backlinks, tbl.backlinks)
+		}
+	}
+}
+
+// equalStrings returns whether the two slices hold the same strings in the
+// same order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestRewriteLink(t *testing.T) {
+	tbls := []struct {
+		ref      string
+		expected string
+	}{
+		{"other.gmi", "other.html"},
+		{"other.gemini", "other.html"},
+		{"../notes/other.md", "../notes/other.html"},
+		{"/notes/other.gmi#part", "/notes/other.html#part"},
+		{"other.gmi?q=1#part", "other.html?q=1#part"},
+		{"a(b).gmi", "a(b).html"},
+		{"my%20notes.md?q=a+b", "my%20notes.html?q=a+b"},
+		{"image.png", "image.png"},
+		{"notes/", "notes/"},
+		{"#part", "#part"},
+		{"gemini://example.tld/other.gmi", "gemini://example.tld/other.gmi"},
+		{"https://example.tld/other.md", "https://example.tld/other.md"},
+		{"//example.tld/other.gmi", "//example.tld/other.gmi"},
+		{"mailto:me@example.tld", "mailto:me@example.tld"},
+	}
+
+	for _, tbl := range tbls {
+		result := gdn.RewriteLink(tbl.ref)
+		if result != tbl.expected {
+			t.Errorf("RewriteLink(%s) gave: %s, expecting: %s",
+				tbl.ref, result, tbl.expected)
+		}
+	}
+}
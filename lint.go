@@ -0,0 +1,38 @@
+package gdn
+
+import (
+	"fmt"
+	"os"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+// Lint checks every Gemini page in the tree with gmi.Lint and returns the
+// problems found, in the order of the leaves in the tree.  The File of each
+// Diagnostic is the Src of the leaf.
+func (b Branch) Lint() ([]gmi.Diagnostic, error) {
+	var diags []gmi.Diagnostic
+
+	err := b.Walk(func(l *Leaf) error {
+		if l.Typ != Gemini {
+			return nil
+		}
+
+		f, err := os.Open(l.Src)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", l.Src, err)
+		}
+		defer f.Close()
+
+		found, err := gmi.Lint(l.Src, f)
+		if err != nil {
+			return err
+		}
+
+		diags = append(diags, found...)
+
+		return nil
+	})
+
+	return diags, err
+}
@@ -0,0 +1,43 @@
+package gdn_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestBranchLint(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	writeFile(t, filepath.Join(tmp, "a.gmi"), "# A\n=>\n")
+	writeFile(t, filepath.Join(tmp, "b.md"), "=>\n")
+	writeFile(t, filepath.Join(tmp, "notes", "c.gmi"), "##\n")
+
+	root := gdn.NewTree(tmp, "dst")
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	diags, err := root.Lint()
+	if err != nil {
+		t.Fatalf("lint encountered an unexpected error: %v", err)
+	}
+
+	var actual []string
+	for _, d := range diags {
+		actual = append(actual, d.String())
+	}
+
+	expected := []string{
+		filepath.Join(tmp, "a.gmi") + ":2:1: error: link has no URL",
+		filepath.Join(tmp, "notes", "c.gmi") +
+			":1:1: warning: heading has no text",
+	}
+	if !equalStrings(actual, expected) {
+		t.Errorf("lint gave: %q, expecting: %q", actual, expected)
+	}
+}
@@ -0,0 +1,307 @@
+package gdn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFile is the name of the build manifest written to the root of the
+// destination by an incremental grow.
+const ManifestFile = ".gdn-manifest.json"
+
+// Manifest records what was grown from each leaf and branch of a tree, so the
+// next incremental grow can skip the leaves that have not changed, and remove
+// what was grown from those that are gone.
+type Manifest struct {
+	// Deps is a hash of what every page depends on, such as the templates in
+	// the ConfigDir and the options used to grow the tree.  When it changes,
+	// every leaf is grown again.
+	Deps string `json:"deps"`
+	// Leaves are the entries for each leaf, by the leaf's Path.
+	Leaves map[string]ManifestEntry `json:"leaves"`
+	// Branches are the entries for each branch, by the branch's Path.  Only
+	// their outputs are recorded: the directories and generated index pages.
+	Branches map[string]ManifestEntry `json:"branches,omitempty"`
+}
+
+// newManifest returns an empty manifest.
+func newManifest() *Manifest {
+	return &Manifest{
+		Leaves:   make(map[string]ManifestEntry),
+		Branches: make(map[string]ManifestEntry),
+	}
+}
+
+// ManifestEntry records the state of a leaf's source when it was grown, and
+// the files that were grown from it.  The files are slash separated and
+// relative to the destination or the capsule, so the manifest holds however
+// the path to either is spelled.
+type ManifestEntry struct {
+	Size int64 `json:"size"`
+	// ModTime is in nanoseconds since the UNIX epoch.
+	ModTime int64 `json:"modTime"`
+	// Deps is a hash of the backlinks to the leaf.
+	Deps string `json:"deps"`
+	// Outputs are the files grown into the destination.
+	Outputs []string `json:"outputs"`
+	// Capsule are the files grown into the capsule, if one was grown.
+	Capsule []string `json:"capsule,omitempty"`
+}
+
+// files returns the paths of the files grown from the leaf, within the given
+// destination and capsule.  The files in the capsule are left out when no
+// capsule is given.
+func (e ManifestEntry) files(dst, capsule string) []string {
+	files := make([]string, 0, len(e.Outputs)+len(e.Capsule))
+
+	for _, out := range e.Outputs {
+		files = append(files, filepath.Join(dst, filepath.FromSlash(out)))
+	}
+
+	if capsule == "" {
+		return files
+	}
+
+	for _, out := range e.Capsule {
+		files = append(files, filepath.Join(capsule, filepath.FromSlash(out)))
+	}
+
+	return files
+}
+
+// LoadManifest loads the build manifest from the destination directory.  An
+// empty manifest is returned if there is none.
+func LoadManifest(dst string) (*Manifest, error) {
+	m := newManifest()
+	file := filepath.Join(dst, ManifestFile)
+
+	b, err := ioutil.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read manifest: %s: %w", file, err)
+	}
+
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("could not parse manifest: %s: %w", file, err)
+	}
+
+	if m.Leaves == nil {
+		m.Leaves = make(map[string]ManifestEntry)
+	}
+
+	if m.Branches == nil {
+		m.Branches = make(map[string]ManifestEntry)
+	}
+
+	return m, nil
+}
+
+// Save writes the manifest to the destination directory.
+func (m *Manifest) Save(dst string) error {
+	file := filepath.Join(dst, ManifestFile)
+
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return fmt.Errorf("could not encode manifest: %w", err)
+	}
+
+	if err := ioutil.WriteFile(file, b, LeafPerm); err != nil {
+		return fmt.Errorf("could not write manifest: %s: %w", file, err)
+	}
+
+	return nil
+}
+
+// entry returns the manifest entry for the leaf as it is now.
+func (l Leaf) entry(g *grower) (ManifestEntry, error) {
+	stat := os.Stat
+	if l.Symlink {
+		stat = os.Lstat
+	}
+
+	info, err := stat(l.Src)
+	if err != nil {
+		return ManifestEntry{}, fmt.Errorf("error getting info for %s: %w",
+			l.Src, err)
+	}
+
+	deps := sha256.New()
+	for _, from := range g.graph.Backlinks(l.Path) {
+		fmt.Fprintf(deps, "%s\n%s\n", from.Path, g.titles[from.Path])
+	}
+
+	e := ManifestEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Deps:    hex.EncodeToString(deps.Sum(nil)),
+		Outputs: []string{strings.TrimPrefix(l.URL(), "/")},
+	}
+
+	if g.capsule != nil {
+		e.Capsule = []string{strings.TrimPrefix(l.CapsuleURL(), "/")}
+	}
+
+	return e, nil
+}
+
+// entry returns the manifest entry for the outputs of the branch: its
+// directories, apart from the root ones, and the index pages generated for it.
+func (b Branch) entry(g *grower) ManifestEntry {
+	dir := strings.TrimPrefix(path.Clean(b.URL()), "/")
+
+	var e ManifestEntry
+
+	if dir != "" {
+		e.Outputs = append(e.Outputs, dir)
+	}
+
+	if !b.hasLeaf(path.Join(b.URL(), IndexFile), (*Leaf).URL) {
+		e.Outputs = append(e.Outputs, path.Join(dir, IndexFile))
+	}
+
+	if g.capsule == nil {
+		return e
+	}
+
+	if dir != "" {
+		e.Capsule = append(e.Capsule, dir)
+	}
+
+	if !b.hasLeaf(path.Join(b.URL(), GeminiIndexFile), (*Leaf).CapsuleURL) {
+		e.Capsule = append(e.Capsule, path.Join(dir, GeminiIndexFile))
+	}
+
+	return e
+}
+
+// fresh returns whether the entry matches the entry of the same leaf in the
+// manifest, and all of the files recorded for it still exist in the given
+// destination and capsule.
+func (m *Manifest) fresh(path string, e ManifestEntry, dst,
+	capsule string) bool {
+	old, ok := m.Leaves[path]
+	if !ok || old.Size != e.Size || old.ModTime != e.ModTime ||
+		old.Deps != e.Deps || !equalPaths(old.Outputs, e.Outputs) ||
+		!equalPaths(old.Capsule, e.Capsule) {
+		return false
+	}
+
+	for _, file := range e.files(dst, capsule) {
+		if _, err := os.Stat(file); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// equalPaths returns whether the lists hold the same paths in the same order.
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stale returns the files and directories recorded in the manifest that are not
+// recorded in the next manifest, within the given destination and capsule.
+// These were grown from leaves and branches that are no longer in the tree.
+// They are sorted in reverse, so the files in a directory come before it.
+func (m *Manifest) stale(next *Manifest, dst, capsule string) []string {
+	keep := make(map[string]bool)
+
+	for _, e := range next.entries() {
+		for _, file := range e.files(dst, capsule) {
+			keep[file] = true
+		}
+	}
+
+	var outputs []string
+
+	for _, e := range m.entries() {
+		for _, file := range e.files(dst, capsule) {
+			if !keep[file] {
+				outputs = append(outputs, file)
+			}
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(outputs)))
+
+	return outputs
+}
+
+// entries returns the entries of both the leaves and the branches.
+func (m *Manifest) entries() []ManifestEntry {
+	entries := make([]ManifestEntry, 0, len(m.Leaves)+len(m.Branches))
+
+	for _, e := range m.Leaves {
+		entries = append(entries, e)
+	}
+
+	for _, e := range m.Branches {
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+// configHash returns a hash of the files in the ConfigDir of the garden rooted
+// at the given source directory along with the options that change what is
+// grown and the fingerprinted URLs of the assets.
+func configHash(src string, opts GrowOptions, assets Assets) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%v\n%+v\n",
+		opts.Capsule, opts.IndexSort, opts.TOC, opts.Site)
+
+	urls := make([]string, 0, len(assets))
+	for u := range assets {
+		urls = append(urls, u)
+	}
+
+	sort.Strings(urls)
+
+	for _, u := range urls {
+		fmt.Fprintf(h, "%s\n%s\n", u, assets[u])
+	}
+
+	dir := filepath.Join(src, ConfigDir)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("could not read directory: %s: %w", dir, err)
+	}
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return "", fmt.Errorf("could not read %s: %w", f.Name(), err)
+		}
+
+		fmt.Fprintf(h, "%s\n%d\n", f.Name(), len(b))
+		h.Write(b)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
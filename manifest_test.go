@@ -0,0 +1,271 @@
+package gdn_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestGrowIncremental(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+	opts := gdn.GrowOptions{Incremental: true}
+
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.LayoutFile),
+		"{{.Body}}{{range .Backlinks}}{{.Name}}{{end}}")
+	writeFile(t, filepath.Join(src, "a.gmi"), "# A\n")
+	writeFile(t, filepath.Join(src, "b.gmi"), "# B\n")
+	writeFile(t, filepath.Join(src, "c.txt"), "C")
+
+	// grow scans the source and grows it incrementally.
+	grow := func() {
+		root := gdn.NewTree(src, dst)
+
+		if err := root.Scan(); err != nil {
+			t.Fatalf("scan encountered an unexpected error: %v", err)
+		}
+
+		if err := root.GrowWith(opts); err != nil {
+			t.Fatalf("grow encountered an unexpected error: %v", err)
+		}
+	}
+
+	// mark replaces the output with a marker to tell if it is grown again.
+	mark := func(name string) {
+		writeFile(t, filepath.Join(dst, name), "marked")
+	}
+
+	// marked expects whether the output still has the marker.
+	marked := func(name string, expected bool) {
+		out := readFile(t, filepath.Join(dst, name))
+		if (out == "marked") != expected {
+			t.Errorf("%s marked gave: %v, expecting: %v (%q)",
+				name, !expected, expected, out)
+		}
+	}
+
+	grow()
+	pathIsRegularFile(t, filepath.Join(dst, gdn.ManifestFile))
+
+	t.Log("+test unchanged leaves are skipped")
+
+	mark("a.html")
+	mark("b.html")
+	mark("c.txt")
+	grow()
+	marked("a.html", true)
+	marked("b.html", true)
+	marked("c.txt", true)
+
+	t.Log("+test changed leaves are grown again")
+
+	later := time.Now().Add(time.Hour)
+	writeFile(t, filepath.Join(src, "c.txt"), "C2")
+
+	err := os.Chtimes(filepath.Join(src, "c.txt"), later, later)
+	if err != nil {
+		t.Fatalf("could not change times: %v", err)
+	}
+
+	grow()
+	marked("a.html", true)
+	marked("c.txt", false)
+
+	t.Log("+test leaves with new backlinks are grown again")
+
+	writeFile(t, filepath.Join(src, "a.gmi"), "# A\n=> b.gmi\n")
+
+	err = os.Chtimes(filepath.Join(src, "a.gmi"), later, later)
+	if err != nil {
+		t.Fatalf("could not change times: %v", err)
+	}
+
+	grow()
+	marked("a.html", false)
+	marked("b.html", false)
+
+	if b := readFile(t, filepath.Join(dst, "b.html")); !strings.HasSuffix(b,
+		"A") {
+		t.Errorf("b.html expected to have a backlink from A, got: %q", b)
+	}
+
+	t.Log("+test missing outputs are grown again")
+
+	if err := os.Remove(filepath.Join(dst, "a.html")); err != nil {
+		t.Fatalf("could not remove a.html: %v", err)
+	}
+
+	grow()
+	pathIsRegularFile(t, filepath.Join(dst, "a.html"))
+
+	t.Log("+test changing a template grows every leaf again")
+
+	mark("a.html")
+	mark("c.txt")
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.LayoutFile),
+		"<p>{{.Body}}</p>")
+	grow()
+	marked("a.html", false)
+	marked("c.txt", false)
+
+	t.Log("+test outputs of removed leaves are removed")
+
+	if err := os.Remove(filepath.Join(src, "c.txt")); err != nil {
+		t.Fatalf("could not remove c.txt: %v", err)
+	}
+
+	grow()
+
+	if _, err := os.Stat(filepath.Join(dst, "c.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected c.txt to be removed, got: %v", err)
+	}
+}
+
+func TestGrowIncrementalPaths(t *testing.T) {
+	tmp, err := filepath.Abs(tmpDir(t))
+	if err != nil {
+		t.Fatalf("could not find absolute path: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get working directory: %v", err)
+	}
+	defer os.Chdir(wd) // nolint: errcheck // back to where the tests run
+
+	src := filepath.Join(tmp, "src")
+	opts := gdn.GrowOptions{
+		Incremental: true,
+		Capsule:     filepath.Join(tmp, "capsule"),
+	}
+
+	writeFile(t, filepath.Join(src, "index.gmi"), "# Home\n")
+	writeFile(t, filepath.Join(src, "notes", "m.gmi"), "# M\n")
+
+	// grow grows the source into the output directory from the directory.
+	grow := func(dir, out string) {
+		t.Helper()
+
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("could not make %s: %v", dir, err)
+		}
+
+		if err := os.Chdir(dir); err != nil {
+			t.Fatalf("could not change to %s: %v", dir, err)
+		}
+
+		root := gdn.NewTree(src, out)
+
+		if err := root.Scan(); err != nil {
+			t.Fatalf("scan encountered an unexpected error: %v", err)
+		}
+
+		if err := root.GrowWith(opts); err != nil {
+			t.Fatalf("grow encountered an unexpected error: %v", err)
+		}
+	}
+
+	grow(tmp, "out")
+
+	t.Log("+test outputs are removed from the same directory spelled otherwise")
+
+	// A file at the same relative path outside of the site is left alone.
+	unrelated := filepath.Join(tmp, "elsewhere", "out", "notes", "m.html")
+	writeFile(t, unrelated, "not grown")
+
+	if err := os.Remove(filepath.Join(src, "notes", "m.gmi")); err != nil {
+		t.Fatalf("could not remove m.gmi: %v", err)
+	}
+
+	grow(filepath.Join(tmp, "elsewhere"), filepath.Join(tmp, "out"))
+
+	for _, grown := range []string{
+		filepath.Join(tmp, "out", "notes", "m.html"),
+		filepath.Join(tmp, "capsule", "notes", "m.gmi"),
+	} {
+		if _, err := os.Stat(grown); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got: %v", grown, err)
+		}
+	}
+
+	if got := readFile(t, unrelated); got != "not grown" {
+		t.Errorf("file outside of the site was changed: %q", got)
+	}
+}
+
+func TestGrowIncrementalRemovedBranch(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+	capsule := filepath.Join(tmp, "capsule")
+	opts := gdn.GrowOptions{Incremental: true, Capsule: capsule}
+
+	writeFile(t, filepath.Join(src, "index.gmi"), "# Home\n")
+	writeFile(t, filepath.Join(src, "notes", "a.gmi"), "# A\n")
+	writeFile(t, filepath.Join(src, "notes", "deep", "b.gmi"), "# B\n")
+	writeFile(t, filepath.Join(src, "kept", "c.gmi"), "# C\n")
+
+	// grow scans the source and grows it incrementally.
+	grow := func() {
+		t.Helper()
+
+		root := gdn.NewTree(src, dst)
+
+		if err := root.Scan(); err != nil {
+			t.Fatalf("scan encountered an unexpected error: %v", err)
+		}
+
+		if err := root.GrowWith(opts); err != nil {
+			t.Fatalf("grow encountered an unexpected error: %v", err)
+		}
+	}
+
+	grow()
+	pathIsRegularFile(t, filepath.Join(dst, "notes", gdn.IndexFile))
+
+	t.Log("+test outputs of a removed directory are removed along with it")
+
+	if err := os.RemoveAll(filepath.Join(src, "notes")); err != nil {
+		t.Fatalf("could not remove notes: %v", err)
+	}
+
+	// A file that was not grown keeps its directory.
+	writeFile(t, filepath.Join(dst, "kept", "extra.txt"), "not grown")
+
+	if err := os.RemoveAll(filepath.Join(src, "kept")); err != nil {
+		t.Fatalf("could not remove kept: %v", err)
+	}
+
+	grow()
+
+	for _, gone := range []string{
+		filepath.Join(dst, "notes"),
+		filepath.Join(capsule, "notes"),
+		filepath.Join(dst, "kept", gdn.IndexFile),
+		filepath.Join(capsule, "kept"),
+	} {
+		if _, err := os.Stat(gone); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got: %v", gone, err)
+		}
+	}
+
+	for _, kept := range []string{
+		filepath.Join(dst, "kept", "extra.txt"),
+		filepath.Join(dst, gdn.IndexFile),
+		filepath.Join(capsule, gdn.GeminiIndexFile),
+	} {
+		if !pathIsRegularFile(t, kept) {
+			t.Errorf("expected %s to be kept", kept)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package gdn
+
+import (
+	"bytes"
+	"strings"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+	"github.com/russross/blackfriday/v2"
+)
+
+// parseMarkdown parses the Markdown source with the same extensions used by
+// blackfriday.Run.
+func parseMarkdown(src []byte) *blackfriday.Node {
+	return blackfriday.New(
+		blackfriday.WithExtensions(blackfriday.CommonExtensions)).Parse(src)
+}
+
+// renderMarkdown renders the Markdown source as HTML in the same way as
+// blackfriday.Run, except that the destination of links and images are passed
+// through RewriteLink, and headings without an ID are given one in the same
+// way as gmi.TOC.  It returns the HTML along with the table of contents.
+func renderMarkdown(src []byte) ([]byte, []gmi.Heading) {
+	root := parseMarkdown(src)
+	r := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+		Flags: blackfriday.CommonHTMLFlags,
+	})
+
+	var (
+		buf bytes.Buffer
+		toc gmi.Contents
+	)
+
+	r.RenderHeader(&buf, root)
+	root.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		isLink := n.Type == blackfriday.Link || n.Type == blackfriday.Image
+		if entering && isLink {
+			n.Destination = []byte(RewriteLink(string(n.Destination)))
+		}
+
+		if entering && n.Type == blackfriday.Heading && !n.IsTitleblock {
+			h := toc.Add(n.Level, nodeText(n))
+			if n.HeadingID == "" {
+				n.HeadingID = h.ID
+			} else {
+				// Keep the ID given in the source, such as {#id}.
+				toc.Headings[len(toc.Headings)-1].ID = n.HeadingID
+			}
+		}
+
+		return r.RenderNode(&buf, n, entering)
+	})
+	r.RenderFooter(&buf, root)
+
+	return buf.Bytes(), toc.Headings
+}
+
+// markdownTitle returns the text of the first level 1 heading in the Markdown
+// source, following the same rule as gmi.Title.
+func markdownTitle(src []byte) string {
+	var title string
+
+	parseMarkdown(src).Walk(func(n *blackfriday.Node,
+		entering bool) blackfriday.WalkStatus {
+		if n.Type != blackfriday.Heading || n.Level != 1 || !entering {
+			return blackfriday.GoToNext
+		}
+
+		title = nodeText(n)
+
+		return blackfriday.Terminate
+	})
+
+	return title
+}
+
+// nodeText returns the text within the node, such as the text of a heading,
+// with surrounding whitespace removed.
+func nodeText(n *blackfriday.Node) string {
+	var text strings.Builder
+
+	n.Walk(func(c *blackfriday.Node, _ bool) blackfriday.WalkStatus {
+		if c.Type == blackfriday.Text || c.Type == blackfriday.Code {
+			text.Write(c.Literal)
+		}
+
+		return blackfriday.GoToNext
+	})
+
+	return strings.TrimSpace(text.String())
+}
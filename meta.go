@@ -0,0 +1,180 @@
+package gdn
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Meta is the metadata of a page, such as its title, tags, creation date or
+// whether it is a draft.  Keys are lower case.
+type Meta map[string]string
+
+// metaDelim is the line that starts and ends a YAML style metadata block.
+const metaDelim = "---"
+
+// metaKey matches the key of a metadata line.
+var metaKey = regexp.MustCompile( // nolint: gochecknoglobals
+	`^([A-Za-z][A-Za-z0-9_-]*):(?:\s+(.*))?$`)
+
+// metaKeys are the keys gdn knows.  Lines that are not between --- lines are
+// only metadata when each of their keys is one of these, so that a page
+// starting with prose such as "Update: I moved things around." keeps it.
+var metaKeys = map[string]bool{ // nolint: gochecknoglobals
+	"title": true, "tags": true, "date": true, "created": true,
+	"updated": true, "draft": true, "private": true, "toc": true,
+	"author": true,
+}
+
+// metaDates are the layouts tried when parsing a date from the metadata.
+var metaDates = []string{ // nolint: gochecknoglobals
+	time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05",
+	"2006-01-02 15:04", "2006-01-02",
+}
+
+// ParseMeta splits the metadata block from the top of the source of a page.  It
+// returns the metadata along with the rest of the source.
+//
+// The block is either key: value lines between two --- lines, like YAML front
+// matter, or key: value lines ending with a blank line.  Without the --- lines,
+// every key must be one that gdn knows: title, tags, date, created, updated,
+// draft, private, toc or author.  Between --- lines, lines starting with # are
+// comments, and a key with no value may be followed by a list of "- item"
+// lines.  Lists, either in that form or written as [a, b], are stored with
+// their items separated by commas.
+//
+// When the source does not start with a metadata block, the metadata is nil and
+// the source is returned unchanged.
+func ParseMeta(src []byte) (Meta, []byte) {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	delimited := trimLine(lines[0]) == metaDelim
+	meta := make(Meta)
+	last := "" // key of the previous line, which a list item belongs to
+	offset := 0
+
+	if delimited {
+		offset = len(lines[0])
+		lines = lines[1:]
+	}
+
+	for _, raw := range lines {
+		offset += len(raw)
+		line := trimLine(raw)
+
+		switch {
+		case len(raw) == 0: // the end of the source, with no end to the block
+			return nil, src
+		case delimited && line == metaDelim:
+			return meta, src[offset:]
+		case !delimited && line == "":
+			if len(meta) == 0 {
+				return nil, src
+			}
+
+			return meta, src[offset:]
+		case delimited && (line == "" || strings.HasPrefix(line, "#")):
+			continue
+		case delimited && last != "" && strings.HasPrefix(line, "- "):
+			item := unquote(strings.TrimSpace(line[2:]))
+			if meta[last] != "" {
+				item = meta[last] + ", " + item
+			}
+
+			meta[last] = item
+
+			continue
+		}
+
+		m := metaKey.FindStringSubmatch(line)
+		if m == nil {
+			return nil, src
+		}
+
+		key, value := strings.ToLower(m[1]), metaValue(m[2])
+		if !delimited && !metaKeys[key] {
+			return nil, src
+		}
+
+		meta[key] = value
+
+		last = ""
+		if value == "" {
+			last = key
+		}
+	}
+
+	return nil, src
+}
+
+// trimLine returns the line without its line ending and trailing spaces.
+func trimLine(line []byte) string {
+	return strings.TrimRight(string(line), " \t\r\n")
+}
+
+// metaValue returns the value of a metadata line without quotes.  A list
+// written as [a, b] has its items separated by commas.
+func metaValue(v string) string {
+	v = strings.TrimSpace(v)
+	if !strings.HasPrefix(v, "[") || !strings.HasSuffix(v, "]") {
+		return unquote(v)
+	}
+
+	items := strings.Split(v[1:len(v)-1], ",")
+	for i, item := range items {
+		items[i] = unquote(strings.TrimSpace(item))
+	}
+
+	return strings.Join(items, ", ")
+}
+
+// unquote removes the single or double quotes around the value, if it has
+// them.
+func unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+
+	return v
+}
+
+// List returns the items of the comma separated list under the key, such as
+// the tags of a page.  Empty items are left out.
+func (m Meta) List(key string) []string {
+	var items []string
+
+	for _, item := range strings.Split(m[key], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Bool returns whether the value under the key is true, such as "true", "yes"
+// or "on".  Missing and unknown values are false.
+func (m Meta) Bool(key string) bool {
+	switch v := strings.ToLower(m[key]); v {
+	case "yes", "on", "y":
+		return true
+	default:
+		b, _ := strconv.ParseBool(v) // nolint: errcheck // false if invalid
+
+		return b
+	}
+}
+
+// Time returns the date and time under the key, such as the date the page was
+// created.  Dates are written like 2006-01-02, optionally followed by a time.
+// The zero time is returned when the value is missing or not a date.
+func (m Meta) Time(key string) time.Time {
+	for _, layout := range metaDates {
+		if t, err := time.Parse(layout, m[key]); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,151 @@
+package gdn_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestParseMeta(t *testing.T) {
+	tbls := []struct {
+		name string
+		src  string
+		meta gdn.Meta
+		rest string
+	}{
+		{
+			"delimited",
+			"---\ntitle: \"A: Title\"\nTags: [a, 'b']\n---\n# Page\n",
+			gdn.Meta{"title": "A: Title", "tags": "a, b"},
+			"# Page\n",
+		},
+		{
+			"delimited with list and comments",
+			"---\r\n# comment\r\ntags:\r\n- a\r\n- b\r\n\r\n" +
+				"draft: true\r\n---\r\n",
+			gdn.Meta{"tags": "a, b", "draft": "true"},
+			"",
+		},
+		{
+			"lines",
+			"title: Page\ncreated: 2020-09-01\n\nText\n",
+			gdn.Meta{"title": "Page", "created": "2020-09-01"},
+			"Text\n",
+		},
+		{"none", "# Page\n\nText\n", nil, "# Page\n\nText\n"},
+		{"empty", "", nil, ""},
+		{"lines without blank line", "title: Page\n", nil, "title: Page\n"},
+		{"lines that are not meta", "Note: this\nis text\n\n", nil,
+			"Note: this\nis text\n\n"},
+		{"unclosed", "---\ntitle: Page\n", nil, "---\ntitle: Page\n"},
+		{"thematic break", "---\nText\n---\n", nil, "---\nText\n---\n"},
+		{"URL is not a key", "https://example.tld/\n\n", nil,
+			"https://example.tld/\n\n"},
+		{"prose with a colon", "Update: I moved things around.\n\nText\n", nil,
+			"Update: I moved things around.\n\nText\n"},
+		{"lines with an unknown key", "title: Page\nmood: calm\n\n", nil,
+			"title: Page\nmood: calm\n\n"},
+		{
+			"delimited with any key",
+			"---\nmood: calm\n---\nText\n",
+			gdn.Meta{"mood": "calm"},
+			"Text\n",
+		},
+	}
+
+	for _, tbl := range tbls {
+		meta, rest := gdn.ParseMeta([]byte(tbl.src))
+
+		if !reflect.DeepEqual(meta, tbl.meta) {
+			t.Errorf("%s: meta gave: %v, expecting: %v",
+				tbl.name, meta, tbl.meta)
+		}
+
+		if string(rest) != tbl.rest {
+			t.Errorf("%s: rest gave: %q, expecting: %q",
+				tbl.name, rest, tbl.rest)
+		}
+	}
+}
+
+func TestMetaValues(t *testing.T) {
+	meta := gdn.Meta{
+		"tags":    "a, , b",
+		"draft":   "yes",
+		"private": "nope",
+		"created": "2020-09-01",
+		"updated": "2020-09-02 10:30",
+	}
+
+	if tags := meta.List("tags"); !reflect.DeepEqual(tags, []string{"a", "b"}) {
+		t.Errorf("List gave: %v, expecting: [a b]", tags)
+	}
+
+	if meta.List("missing") != nil {
+		t.Errorf("List of a missing key should be nil")
+	}
+
+	if !meta.Bool("draft") || meta.Bool("private") || meta.Bool("missing") {
+		t.Errorf("Bool gave: %v, %v, %v, expecting: true, false, false",
+			meta.Bool("draft"), meta.Bool("private"), meta.Bool("missing"))
+	}
+
+	expected := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	if created := meta.Time("created"); !created.Equal(expected) {
+		t.Errorf("Time gave: %v, expecting: %v", created, expected)
+	}
+
+	expected = time.Date(2020, 9, 2, 10, 30, 0, 0, time.UTC)
+	if updated := meta.Time("updated"); !updated.Equal(expected) {
+		t.Errorf("Time gave: %v, expecting: %v", updated, expected)
+	}
+
+	if !meta.Time("tags").IsZero() {
+		t.Errorf("Time of a value that is not a date should be zero")
+	}
+}
+
+func TestGrowWithMeta(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.LayoutFile),
+		"{{.Title}}|{{.Meta.List \"tags\"}}|{{.Body}}")
+	writeFile(t, filepath.Join(src, gdn.ConfigDir, gdn.IndexFile),
+		"{{range .Leaves}}{{.Name}}:{{.Meta.author}},{{end}}")
+	writeFile(t, filepath.Join(src, "page.gmi"),
+		"---\ntitle: Meta Title\ntags: [a, b]\nauthor: Kiba\n---\n# Heading\n")
+	writeFile(t, filepath.Join(src, "plain.md"), "Text\n")
+
+	root := gdn.NewTree(src, dst)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	if err := root.Grow(); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	t.Log("+test the metadata is stripped and given to the layout")
+
+	expected := "Meta Title|[a b]|<h1 id=\"heading\">Heading</h1>\n"
+	if page := readFile(t, filepath.Join(dst, "page.html")); page != expected {
+		t.Errorf("page gave: %q, expecting: %q", page, expected)
+	}
+
+	t.Log("+test the metadata is given to the index")
+
+	expected = "Home|[]|Meta Title:Kiba,plain:,"
+	if page := readFile(t, filepath.Join(dst, gdn.IndexFile)); page !=
+		expected {
+		t.Errorf("index gave: %q, expecting: %q", page, expected)
+	}
+}
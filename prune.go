@@ -0,0 +1,256 @@
+package gdn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrUnsafePrune occurs when pruning a directory that may hold files which were
+// not grown, such as the source of the garden.
+var ErrUnsafePrune = errors.New("refusing to prune")
+
+// Stale returns the files and directories in the destination, and in the
+// capsule when opts.Capsule is set, that growing the tree with the options
+// does not produce.  They are sorted, and a stale directory is listed without
+// the files within it.  Hidden files and directories, such as .git or the
+// ManifestFile, are never stale.
+//
+// It fails with ErrUnsafePrune when the destination or the capsule is the
+// source of the garden, is within it, or holds it.  Nothing is stale when the
+// destination does not exist yet.
+func (b Branch) Stale(opts GrowOptions) ([]string, error) {
+	if err := b.checkPrune(opts); err != nil {
+		return nil, err
+	}
+
+	if _, err := b.fingerprint(opts.Fingerprint); err != nil {
+		return nil, err
+	}
+
+	dirs := b.pruneDirs(opts)
+	outputs := b.outputs(opts)
+
+	var stale []string
+
+	for _, dir := range dirs {
+		found, err := staleIn(dir, outputs, dirs)
+		if err != nil {
+			return nil, err
+		}
+
+		stale = append(stale, found...)
+	}
+
+	sort.Strings(stale)
+
+	return stale, nil
+}
+
+// Prune removes the files and directories that Stale lists, so the
+// destination only holds what growing the tree with the options produces.  It
+// returns what was removed.  Prune is meant to be run after growing, since
+// the outputs that have not been grown yet are not removed either.
+func (b Branch) Prune(opts GrowOptions) ([]string, error) {
+	stale, err := b.Stale(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := b.pruneDirs(opts)
+	removed := make([]string, 0, len(stale))
+
+	for _, p := range stale {
+		if !withinAny(dirs, p) {
+			return removed, fmt.Errorf("%w: %s is outside of %s",
+				ErrUnsafePrune, p, strings.Join(dirs, " and "))
+		}
+
+		if err := os.RemoveAll(p); err != nil {
+			return removed, fmt.Errorf("could not remove %s: %w", p, err)
+		}
+
+		removed = append(removed, p)
+	}
+
+	return removed, nil
+}
+
+// pruneDirs returns the directories that are pruned: the destination, and the
+// capsule if one is grown.
+func (b Branch) pruneDirs(opts GrowOptions) []string {
+	dirs := []string{b.Dst}
+	if opts.Capsule != "" {
+		dirs = append(dirs, opts.Capsule)
+	}
+
+	return dirs
+}
+
+// checkPrune returns ErrUnsafePrune when pruning any of the directories that
+// are pruned is not safe.  See checkPruneDir.
+func (b Branch) checkPrune(opts GrowOptions) error {
+	for _, dir := range b.pruneDirs(opts) {
+		if err := checkPruneDir(b.Src, dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// outputs returns the cleaned paths of the files and directories that growing
+// the tree with the options produces.
+func (b Branch) outputs(opts GrowOptions) map[string]bool {
+	out := make(map[string]bool)
+	add := func(p string) { out[filepath.Clean(p)] = true }
+
+	for _, branch := range b.branches() {
+		add(branch.Dst)
+
+		if !branch.hasLeaf(path.Join(branch.URL(), IndexFile), (*Leaf).URL) {
+			add(filepath.Join(branch.Dst, IndexFile))
+		}
+
+		for _, l := range branch.Leaves {
+			add(l.Dst())
+
+			if opts.Capsule != "" {
+				add(filepath.Join(opts.Capsule,
+					filepath.FromSlash(l.CapsuleURL())))
+			}
+		}
+
+		if opts.Capsule == "" {
+			continue
+		}
+
+		dir := filepath.Join(opts.Capsule, branch.Path)
+		add(dir)
+
+		url := path.Join(branch.URL(), GeminiIndexFile)
+		if !branch.hasLeaf(url, (*Leaf).CapsuleURL) {
+			add(filepath.Join(dir, GeminiIndexFile))
+		}
+	}
+
+	if opts.Fingerprint {
+		add(filepath.Join(b.Dst, AssetsFile))
+	}
+
+	if opts.Feed {
+		add(filepath.Join(b.Dst, FeedFile))
+
+		if opts.Capsule != "" {
+			add(filepath.Join(opts.Capsule, GemfeedFile))
+		}
+	}
+
+	return out
+}
+
+// staleIn returns the files and directories within the directory that are not
+// outputs.  Hidden files and directories are skipped, as are the other
+// directories being pruned, and symbolic links are not followed.
+func staleIn(dir string, outputs map[string]bool,
+	pruned []string) ([]string, error) {
+	var stale []string
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo,
+		err error) error {
+		if os.IsNotExist(err) && p == dir {
+			return filepath.SkipDir
+		} else if err != nil {
+			return fmt.Errorf("could not read %s: %w", p, err)
+		}
+
+		if p == dir {
+			return nil
+		}
+
+		isDir := info.IsDir()
+
+		switch {
+		case strings.HasPrefix(info.Name(), "."), isPruned(pruned, p):
+		case !outputs[filepath.Clean(p)]:
+			stale = append(stale, p)
+		default:
+			return nil
+		}
+
+		if isDir {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return stale, err
+}
+
+// isPruned returns whether the path is one of the directories being pruned.
+func isPruned(pruned []string, p string) bool {
+	for _, dir := range pruned {
+		if filepath.Clean(dir) == filepath.Clean(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPruneDir returns ErrUnsafePrune when the directory to prune is the
+// source directory, is within it, or holds it.
+func checkPruneDir(src, dir string) error {
+	srcAbs, err := realPath(src)
+	if err != nil {
+		return err
+	}
+
+	dirAbs, err := realPath(dir)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case within(srcAbs, dirAbs):
+		return fmt.Errorf("%w: %s is the source %s or is within it",
+			ErrUnsafePrune, dir, src)
+	case within(dirAbs, srcAbs):
+		return fmt.Errorf("%w: %s holds the source %s",
+			ErrUnsafePrune, dir, src)
+	}
+
+	return nil
+}
+
+// within returns whether the path is the directory or is within it.  Both are
+// absolute and clean.
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+
+	return err == nil && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// withinAny returns whether the path is within any of the directories, but is
+// not one of the directories itself.
+func withinAny(dirs []string, p string) bool {
+	for _, dir := range dirs {
+		dirAbs, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+
+		abs, err := filepath.Abs(p)
+		if err == nil && abs != dirAbs && within(dirAbs, abs) {
+			return true
+		}
+	}
+
+	return false
+}
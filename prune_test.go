@@ -0,0 +1,161 @@
+package gdn_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+func TestPrune(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+	capsule := filepath.Join(tmp, "capsule")
+
+	writeFile(t, filepath.Join(src, "index.gmi"), "# Home\n")
+	writeFile(t, filepath.Join(src, "notes", "a.gmi"), "# A\n")
+	writeFile(t, filepath.Join(src, "notes", "b.md"), "# B\n")
+
+	// Left over from growing files that were since renamed or deleted.
+	writeFile(t, filepath.Join(dst, "old.html"), "old")
+	writeFile(t, filepath.Join(dst, "notes", "c.html"), "old")
+	writeFile(t, filepath.Join(dst, "gone", "index.html"), "old")
+	writeFile(t, filepath.Join(dst, ".git", "HEAD"), "keep")
+	writeFile(t, filepath.Join(capsule, "notes", "c.gmi"), "old")
+
+	root := gdn.NewTree(src, dst)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	opts := gdn.GrowOptions{Capsule: capsule, Feed: true}
+	if err := root.GrowWith(opts); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(capsule, "notes", "c.gmi"),
+		filepath.Join(dst, "gone"),
+		filepath.Join(dst, "notes", "c.html"),
+		filepath.Join(dst, "old.html"),
+	}
+
+	t.Log("+test listing the stale files leaves them alone")
+
+	stale, err := root.Stale(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(stale, expected) {
+		t.Errorf("Stale gave: %q, expecting: %q", stale, expected)
+	}
+
+	pathIsRegularFile(t, filepath.Join(dst, "old.html"))
+
+	t.Log("+test pruning removes only the stale files")
+
+	removed, err := root.Prune(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(removed, expected) {
+		t.Errorf("Prune gave: %q, expecting: %q", removed, expected)
+	}
+
+	for _, p := range expected {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed: %v", p, err)
+		}
+	}
+
+	for _, name := range []string{
+		"index.html", "atom.xml", ".git/HEAD",
+		"notes/index.html", "notes/a.html", "notes/b.html",
+	} {
+		pathIsRegularFile(t, filepath.Join(dst, filepath.FromSlash(name)))
+	}
+
+	for _, name := range []string{
+		"index.gmi", "feed.gmi", "notes/index.gmi", "notes/a.gmi",
+		"notes/b.gmi",
+	} {
+		pathIsRegularFile(t, filepath.Join(capsule, filepath.FromSlash(name)))
+	}
+
+	t.Log("+test growing with prune")
+
+	writeFile(t, filepath.Join(dst, "old.html"), "old")
+
+	opts.Prune = true
+	if err := root.GrowWith(opts); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "old.html")); !os.IsNotExist(err) {
+		t.Errorf("old.html should be removed: %v", err)
+	}
+}
+
+func TestPruneUnsafe(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	writeFile(t, filepath.Join(src, "index.gmi"), "# Home\n")
+
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		t.Fatalf("could not find absolute path: %v", err)
+	}
+
+	if err := os.Symlink(abs, filepath.Join(tmp, "link")); err != nil {
+		t.Fatalf("could not link: %v", err)
+	}
+
+	tbls := []struct {
+		name    string
+		dst     string
+		capsule string
+	}{
+		{"same as the source", src, ""},
+		{"within the source", filepath.Join(src, "dist"), ""},
+		{"holds the source", tmp, ""},
+		{"linked to the source", filepath.Join(tmp, "link", "dist"), ""},
+		{"capsule within the source", filepath.Join(tmp, "dst"),
+			filepath.Join(src, "capsule")},
+	}
+
+	for _, tbl := range tbls {
+		root := gdn.NewTree(src, tbl.dst)
+
+		if err := root.Scan(); err != nil {
+			t.Fatalf("%s: scan encountered an unexpected error: %v",
+				tbl.name, err)
+		}
+
+		opts := gdn.GrowOptions{Capsule: tbl.capsule, Prune: true}
+
+		if _, err := root.Stale(opts); !errors.Is(err, gdn.ErrUnsafePrune) {
+			t.Errorf("%s: Stale expected %v, got: %v",
+				tbl.name, gdn.ErrUnsafePrune, err)
+		}
+
+		if err := root.GrowWith(opts); !errors.Is(err, gdn.ErrUnsafePrune) {
+			t.Errorf("%s: GrowWith expected %v, got: %v",
+				tbl.name, gdn.ErrUnsafePrune, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(src, "dist")); !os.IsNotExist(err) {
+		t.Errorf("nothing should be grown when pruning is not safe: %v", err)
+	}
+}
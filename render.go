@@ -0,0 +1,225 @@
+package gdn
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"git.sr.ht/~kiba/gdn/gmi"
+)
+
+// ErrUnknownType occurs when adding an extension to a type that is not in the
+// Registry.
+var ErrUnknownType = errors.New("unknown file type")
+
+// Rendered is a page rendered into HTML by a Renderer.
+type Rendered struct {
+	// Title is the title found in the page, such as its first heading, or
+	// empty if it has none.  A title in the page's metadata takes its place.
+	Title string
+	// Body is the HTML of the page, which is then wrapped in the layout.
+	Body []byte
+	// TOC are the headings of the page, with the IDs given to them in the
+	// Body, for the table of contents.
+	TOC []gmi.Heading
+}
+
+// Renderer renders the source of a page into HTML.  The source is given
+// without its metadata block.
+type Renderer interface {
+	Render(src []byte) (Rendered, error)
+}
+
+// RendererFunc is a function used as a Renderer.
+type RendererFunc func(src []byte) (Rendered, error)
+
+// Render calls f(src).
+func (f RendererFunc) Render(src []byte) (Rendered, error) {
+	return f(src)
+}
+
+// Registry maps file extensions to the FileType of the file, and each FileType
+// to the Renderer that grows files of that type into pages.  Files of a type
+// without a Renderer are copied as they are.  It is safe to use from multiple
+// goroutines.
+type Registry struct {
+	mu        sync.RWMutex
+	types     map[string]FileType // by extension
+	names     map[FileType]string
+	renderers map[FileType]Renderer
+	next      FileType // the FileType given to the next new type
+}
+
+// NewRegistry returns an empty registry.  It knows the names of the Markdown
+// and Gemini types, but not their extensions or renderers.
+func NewRegistry() *Registry {
+	return &Registry{
+		types: make(map[string]FileType),
+		names: map[FileType]string{
+			Unknown:  "Unknown",
+			Markdown: "Markdown",
+			Gemini:   "Gemini",
+		},
+		renderers: make(map[FileType]Renderer),
+		next:      Gemini + 1,
+	}
+}
+
+// DefaultRegistry is the registry used to scan and grow trees.  It has the
+// Markdown and Gemini renderers registered.
+var DefaultRegistry = NewRegistry() // nolint: gochecknoglobals
+
+// init registers the Markdown and Gemini renderers in the DefaultRegistry.  It
+// cannot be done when the DefaultRegistry is made, since the Gemini renderer
+// looks up the types of the files it links to in the DefaultRegistry.
+func init() { // nolint: gochecknoinits
+	DefaultRegistry.Register("Markdown", RendererFunc(renderMarkdownPage),
+		".md", ".mkd", ".markdown")
+	DefaultRegistry.Register("Gemini", RendererFunc(renderGeminiPage),
+		".gmi", ".gemini")
+}
+
+// Register registers the renderer for files with the given extensions, which
+// include the dot (e.g. ".org").  It returns the FileType of those files.
+//
+// When a type with the same name is already registered, such as "Gemini", its
+// renderer is replaced and the extensions are added to it.  Otherwise a new
+// FileType is made with the name.  An extension registered before is moved to
+// the type.
+func (r *Registry) Register(name string, rend Renderer,
+	exts ...string) FileType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	typ, ok := r.typeByName(name)
+	if !ok {
+		typ = r.next
+		r.next++
+		r.names[typ] = name
+	}
+
+	r.renderers[typ] = rend
+
+	for _, ext := range exts {
+		r.types[ext] = typ
+	}
+
+	return typ
+}
+
+// AddExtension maps the extension to the type with the given name, such as
+// "Gemini", so files with the extension are grown like files of that type.
+// Mapping it to "Unknown" copies the files as they are.
+func (r *Registry) AddExtension(ext, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	typ, ok := r.typeByName(name)
+	if name == r.names[Unknown] {
+		typ, ok = Unknown, true
+	}
+
+	if !ok {
+		return fmt.Errorf("could not add %s: %w: %s", ext, ErrUnknownType, name)
+	}
+
+	r.types[ext] = typ
+
+	return nil
+}
+
+// typeByName returns the type with the name.
+func (r *Registry) typeByName(name string) (FileType, bool) {
+	for typ, n := range r.names {
+		if typ != Unknown && n == name {
+			return typ, true
+		}
+	}
+
+	return Unknown, false
+}
+
+// TypeByExtension returns the type of files with the extension, or Unknown if
+// the extension is not registered.
+func (r *Registry) TypeByExtension(ext string) FileType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	typ, ok := r.types[ext]
+	if !ok {
+		return Unknown
+	}
+
+	return typ
+}
+
+// Renderer returns the renderer of the type, or nil if it has none.
+func (r *Registry) Renderer(typ FileType) Renderer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.renderers[typ]
+}
+
+// Name returns the name of the type, or "Unknown" if it is not registered.
+func (r *Registry) Name(typ FileType) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name, ok := r.names[typ]
+	if !ok {
+		return "Unknown"
+	}
+
+	return name
+}
+
+// Register registers the renderer for files with the given extensions in the
+// DefaultRegistry.  See Registry.Register.
+func Register(name string, rend Renderer, exts ...string) FileType {
+	return DefaultRegistry.Register(name, rend, exts...)
+}
+
+// TypeByExtension will look up the type by its extension in the
+// DefaultRegistry.
+func TypeByExtension(ext string) FileType {
+	return DefaultRegistry.TypeByExtension(ext)
+}
+
+// isPage returns whether files of the type are grown into pages, which is when
+// the type has a renderer in the DefaultRegistry.
+func (t FileType) isPage() bool {
+	return DefaultRegistry.Renderer(t) != nil
+}
+
+// renderMarkdownPage renders Markdown into HTML.  See renderMarkdown.
+func renderMarkdownPage(src []byte) (Rendered, error) {
+	body, toc := renderMarkdown(src)
+
+	return Rendered{markdownTitle(src), body, toc}, nil
+}
+
+// renderGeminiPage renders Gemini text into HTML.  Links to pages are pointed
+// to the HTML pages grown from them, and headings are given IDs so they can be
+// linked to from a table of contents.
+func renderGeminiPage(src []byte) (Rendered, error) {
+	title, err := gmi.Title(bytes.NewReader(src))
+	if err != nil {
+		return Rendered{}, fmt.Errorf("error reading Gemini text: %w", err)
+	}
+
+	toc, err := gmi.TOC(bytes.NewReader(src))
+	if err != nil {
+		return Rendered{}, fmt.Errorf("error reading Gemini text: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	r := gmi.HTMLRenderer{LinkURL: RewriteLink, HeadingIDs: true}
+	if err := r.Render(&buf, bytes.NewReader(src)); err != nil {
+		return Rendered{}, err
+	}
+
+	return Rendered{title, buf.Bytes(), toc}, nil
+}
@@ -0,0 +1,111 @@
+package gdn_test
+
+import (
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+// plainText renders plain text as preformatted HTML, titled by its first line.
+func plainText(src []byte) (gdn.Rendered, error) {
+	text := string(src)
+	title := strings.SplitN(text, "\n", 2)[0]
+
+	return gdn.Rendered{
+		Title: title,
+		Body:  []byte("<pre>" + html.EscapeString(text) + "</pre>"),
+	}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	r := gdn.NewRegistry()
+
+	t.Log("-test an empty registry knows no extensions")
+
+	if typ := r.TypeByExtension(".md"); typ != gdn.Unknown {
+		t.Errorf("TypeByExtension(.md) gave: %s, expecting: Unknown", typ)
+	}
+
+	t.Log("+test registering a new type")
+
+	plain := r.Register("Plain", gdn.RendererFunc(plainText), ".plain", ".pln")
+	if plain == gdn.Unknown || plain == gdn.Markdown || plain == gdn.Gemini {
+		t.Fatalf("Register gave an existing type: %d", plain)
+	}
+
+	for _, ext := range []string{".plain", ".pln"} {
+		if typ := r.TypeByExtension(ext); typ != plain {
+			t.Errorf("TypeByExtension(%s) gave: %d, expecting: %d",
+				ext, typ, plain)
+		}
+	}
+
+	if name := r.Name(plain); name != "Plain" {
+		t.Errorf("Name gave: %s, expecting: Plain", name)
+	}
+
+	if r.Renderer(plain) == nil || r.Renderer(gdn.Unknown) != nil {
+		t.Errorf("Renderer gave the wrong renderers")
+	}
+
+	t.Log("+test registering a type with the same name adds to it")
+
+	typ := r.Register("Plain", gdn.RendererFunc(plainText), ".txt")
+	if typ != plain {
+		t.Errorf("Register gave: %d, expecting: %d", typ, plain)
+	}
+
+	if typ := r.TypeByExtension(".txt"); typ != plain {
+		t.Errorf("TypeByExtension(.txt) gave: %d, expecting: %d", typ, plain)
+	}
+
+	t.Log("+test registering a known type by name")
+
+	typ = r.Register("Gemini", gdn.RendererFunc(plainText), ".gmi")
+	if typ != gdn.Gemini {
+		t.Errorf("Register gave: %d, expecting: %d", typ, gdn.Gemini)
+	}
+}
+
+func TestGrowRegistered(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+
+	gdn.Register("Test Text", gdn.RendererFunc(plainText), ".testtext")
+
+	writeFile(t, filepath.Join(src, "notes.testtext"),
+		"My Notes\n<b>not bold</b>\n")
+	writeFile(t, filepath.Join(src, "index.gmi"), "=> notes.testtext Notes\n")
+
+	root := gdn.NewTree(src, dst)
+
+	if err := root.Scan(); err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	if err := root.Grow(); err != nil {
+		t.Fatalf("grow encountered an unexpected error: %v", err)
+	}
+
+	notes := readFile(t, filepath.Join(dst, "notes.html"))
+	for _, want := range []string{
+		"<title>My Notes</title>",
+		"<pre>My Notes\n&lt;b&gt;not bold&lt;/b&gt;\n</pre>",
+	} {
+		if !strings.Contains(notes, want) {
+			t.Errorf("notes.html does not contain %q:\n%s", want, notes)
+		}
+	}
+
+	index := readFile(t, filepath.Join(dst, "index.html"))
+	if !strings.Contains(index, `<a href="notes.html">Notes</a>`) {
+		t.Errorf("index.html does not link to notes.html:\n%s", index)
+	}
+}
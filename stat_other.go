@@ -0,0 +1,10 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package gdn
+
+// statID returns the real path of the file at the path, since its device and
+// inode are not known on this platform.
+func statID(p string) (fileID, error) {
+	return pathID(p)
+}
@@ -0,0 +1,30 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package gdn
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// statID returns the device and inode of the file at the path, following
+// symbolic links.
+func statID(p string) (fileID, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return fileID{}, fmt.Errorf("could not stat %s: %w", p, err)
+	}
+
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return pathID(p)
+	}
+
+	// The types of Dev and Ino differ between platforms.
+	return fileID{
+		dev: uint64(st.Dev), // nolint: unconvert // not uint64 everywhere
+		ino: uint64(st.Ino), // nolint: unconvert // not uint64 everywhere
+	}, nil
+}
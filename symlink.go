@@ -0,0 +1,208 @@
+package gdn
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ErrUnknownSymlinks occurs when decoding a SymlinkPolicy with an unknown name.
+var ErrUnknownSymlinks = errors.New("unknown symlink policy")
+
+// SymlinkPolicy is how Scan handles the symbolic links in a garden.
+type SymlinkPolicy int
+
+const (
+	// FollowSymlinks scans what symbolic links point to as if it were in the
+	// garden, so a link to a directory is scanned as a Branch and a link to a
+	// file is grown as a Leaf.  This is the default.
+	FollowSymlinks SymlinkPolicy = iota
+	// SkipSymlinks leaves symbolic links out of the tree.
+	SkipSymlinks
+	// CopySymlinks copies symbolic links into the site as links with the same
+	// target, without scanning or rendering what they point to.
+	CopySymlinks
+)
+
+// String returns the string representation of the SymlinkPolicy.  For example,
+// for SkipSymlinks it will return the string "skip".
+func (p SymlinkPolicy) String() string {
+	switch p {
+	case FollowSymlinks:
+		return "follow"
+	case SkipSymlinks:
+		return "skip"
+	case CopySymlinks:
+		return "copy"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the SymlinkPolicy as its name.
+func (p SymlinkPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText decodes the SymlinkPolicy from its name, such as "skip".
+func (p *SymlinkPolicy) UnmarshalText(text []byte) error {
+	for _, policy := range []SymlinkPolicy{
+		FollowSymlinks, SkipSymlinks, CopySymlinks,
+	} {
+		if policy.String() == string(text) {
+			*p = policy
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q", ErrUnknownSymlinks, text)
+}
+
+// fileID identifies a directory while it is being scanned, so that it is
+// found again when it is reached through a symbolic link.  Where the device
+// and inode of a file are known they identify it, otherwise its real path
+// does.
+type fileID struct {
+	dev, ino uint64
+	path     string
+}
+
+// CopyLink creates a symbolic link at the destination with the same target as
+// the symbolic link at the source.  A file already at the destination is
+// replaced.
+func CopyLink(src, dest string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return fmt.Errorf("could not read link (%s) to copy: %w", src, err)
+	}
+
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not replace dest (%s) with link: %w", dest,
+			err)
+	}
+
+	if err := os.Symlink(target, dest); err != nil {
+		return fmt.Errorf("could not link (%s) to (%s): %w", dest, target, err)
+	}
+
+	return nil
+}
+
+// pathID returns the real path of the file at the path as its fileID.
+func pathID(p string) (fileID, error) {
+	resolved, err := realPath(p)
+
+	return fileID{path: resolved}, err
+}
+
+// WalkSymlinks walks the file tree rooted at root like filepath.Walk, calling
+// fn for each file and directory, but handles symbolic links with the policy
+// like Scan does.  With FollowSymlinks, links are walked as what they point
+// to, so the directories they point to are walked too.  A directory that is
+// reached again within itself is given to fn with ErrSymlinkLoop and is not
+// walked again.  With SkipSymlinks links are left out, and with CopySymlinks
+// they are given to fn as links.
+func WalkSymlinks(root string, policy SymlinkPolicy,
+	fn filepath.WalkFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		w := &walker{policy: policy, fn: fn, ancestors: make(map[fileID]bool)}
+		err = w.walk(root, info)
+	}
+
+	if errors.Is(err, filepath.SkipDir) {
+		return nil
+	}
+
+	return err
+}
+
+// walker holds what is needed while walking a file tree with WalkSymlinks.
+type walker struct {
+	policy    SymlinkPolicy
+	fn        filepath.WalkFunc
+	ancestors map[fileID]bool // the directories being walked
+}
+
+// walk calls fn for the file, then walks the directory if it is one.
+func (w *walker) walk(p string, info os.FileInfo) error {
+	if !info.IsDir() {
+		return w.fn(p, info, nil)
+	}
+
+	id, err := statID(p)
+	if err != nil {
+		return w.fn(p, info, err)
+	}
+
+	if w.ancestors[id] {
+		err := w.fn(p, info, fmt.Errorf("%s: %w", p, ErrSymlinkLoop))
+		if errors.Is(err, filepath.SkipDir) {
+			return nil
+		}
+
+		return err
+	}
+
+	if err := w.fn(p, info, nil); err != nil {
+		if errors.Is(err, filepath.SkipDir) {
+			return nil
+		}
+
+		return err
+	}
+
+	w.ancestors[id] = true
+	defer delete(w.ancestors, id)
+
+	files, err := ioutil.ReadDir(p)
+	if err != nil {
+		return w.fn(p, info, err)
+	}
+
+	for _, f := range files {
+		child := filepath.Join(p, f.Name())
+
+		target, walkErr := w.symlink(child, f)
+		if walkErr == nil && target != nil {
+			walkErr = w.walk(child, target)
+		}
+
+		if errors.Is(walkErr, filepath.SkipDir) {
+			return nil
+		} else if walkErr != nil {
+			return walkErr
+		}
+	}
+
+	return nil
+}
+
+// symlink returns the info of the file at the path as the policy handles it.
+// Links that are followed are given the info of their target, and links that
+// are skipped, or whose target cannot be found, are given nil.  Other files
+// are returned as they are.
+func (w *walker) symlink(p string, info os.FileInfo) (os.FileInfo, error) {
+	if info.Mode()&os.ModeSymlink == 0 {
+		return info, nil
+	}
+
+	switch w.policy {
+	case SkipSymlinks:
+		return nil, nil
+	case CopySymlinks:
+		return info, nil
+	case FollowSymlinks:
+	}
+
+	target, err := os.Stat(p)
+	if err != nil {
+		return nil, w.fn(p, info, err)
+	}
+
+	return target, nil
+}
@@ -0,0 +1,192 @@
+package gdn_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.sr.ht/~kiba/gdn"
+)
+
+// symlink links the path to the target, failing the test if it cannot.
+func symlink(t *testing.T, target, path string) {
+	t.Helper()
+
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatalf("could not link %s to %s: %v", path, target, err)
+	}
+}
+
+func TestScanSymlinks(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+
+	writeFile(t, filepath.Join(src, "index.gmi"), "# Home\n")
+	writeFile(t, filepath.Join(tmp, "shared", "note.gmi"), "# Note\n")
+	symlink(t, "index.gmi", filepath.Join(src, "alias.gmi"))
+	symlink(t, "../shared", filepath.Join(src, "shared"))
+
+	tbls := []struct {
+		policy   gdn.SymlinkPolicy
+		expected []string
+	}{
+		{
+			gdn.FollowSymlinks,
+			[]string{"/alias.gmi", "/index.gmi", "/shared/note.gmi"},
+		},
+		{gdn.SkipSymlinks, []string{"/index.gmi"}},
+		{gdn.CopySymlinks, []string{"/alias.gmi", "/index.gmi", "/shared"}},
+	}
+
+	for _, tbl := range tbls {
+		root := gdn.NewTree(src, filepath.Join(tmp, "dst"))
+
+		err := root.ScanWith(gdn.ScanOptions{Symlinks: tbl.policy})
+		if err != nil {
+			t.Fatalf("%s: scan encountered an unexpected error: %v",
+				tbl.policy, err)
+		}
+
+		var paths []string
+
+		err = root.Walk(func(l *gdn.Leaf) error {
+			paths = append(paths, filepath.ToSlash(l.Path))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: walk encountered an unexpected error: %v",
+				tbl.policy, err)
+		}
+
+		if !equalStrings(paths, tbl.expected) {
+			t.Errorf("%s: scan gave: %v, expecting: %v",
+				tbl.policy, paths, tbl.expected)
+		}
+	}
+}
+
+func TestScanSymlinkLoop(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	writeFile(t, filepath.Join(tmp, "notes", "index.gmi"), "# Notes\n")
+	symlink(t, "..", filepath.Join(tmp, "notes", "up"))
+
+	root := gdn.NewTree(tmp, filepath.Join(tmp, "dst"))
+
+	err := root.Scan()
+	if !errors.Is(err, gdn.ErrSymlinkLoop) {
+		t.Errorf("expected %v, got: %v", gdn.ErrSymlinkLoop, err)
+	}
+
+	t.Log("+test a loop is not followed when symbolic links are skipped")
+
+	root = gdn.NewTree(tmp, filepath.Join(tmp, "dst"))
+
+	err = root.ScanWith(gdn.ScanOptions{Symlinks: gdn.SkipSymlinks})
+	if err != nil {
+		t.Errorf("scan encountered an unexpected error: %v", err)
+	}
+}
+
+func TestGrowCopiedSymlinks(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+	capsule := filepath.Join(tmp, "capsule")
+
+	writeFile(t, filepath.Join(src, "index.gmi"), "# Home\n")
+	writeFile(t, filepath.Join(tmp, "shared", "note.gmi"), "# Note\n")
+	symlink(t, "index.html", filepath.Join(src, "home.html"))
+	symlink(t, "../shared", filepath.Join(src, "shared"))
+
+	root := gdn.NewTree(src, dst)
+
+	err := root.ScanWith(gdn.ScanOptions{Symlinks: gdn.CopySymlinks})
+	if err != nil {
+		t.Fatalf("scan encountered an unexpected error: %v", err)
+	}
+
+	// Growing twice replaces the links grown the first time.
+	for i := 0; i < 2; i++ {
+		if err := root.GrowWith(gdn.GrowOptions{Capsule: capsule}); err != nil {
+			t.Fatalf("grow encountered an unexpected error: %v", err)
+		}
+	}
+
+	for _, dir := range []string{dst, capsule} {
+		for name, expected := range map[string]string{
+			"home.html": "index.html",
+			"shared":    "../shared",
+		} {
+			target, err := os.Readlink(filepath.Join(dir, name))
+			if err != nil {
+				t.Errorf("%s was not copied as a link: %v", name, err)
+			} else if target != expected {
+				t.Errorf("%s links to %s, expecting: %s",
+					name, target, expected)
+			}
+		}
+	}
+}
+
+func TestWalkSymlinks(t *testing.T) {
+	tmp := tmpDir(t)
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+
+	writeFile(t, filepath.Join(src, "index.gmi"), "# Home\n")
+	writeFile(t, filepath.Join(tmp, "shared", "note.gmi"), "# Note\n")
+	symlink(t, "../shared", filepath.Join(src, "shared"))
+	symlink(t, "..", filepath.Join(tmp, "shared", "up"))
+
+	tbls := []struct {
+		policy   gdn.SymlinkPolicy
+		expected []string
+	}{
+		{
+			gdn.FollowSymlinks,
+			[]string{
+				"/", "/index.gmi", "/shared", "/shared/note.gmi", "/shared/up",
+				"/shared/up/shared (loop)", "/shared/up/src (loop)",
+			},
+		},
+		{gdn.SkipSymlinks, []string{"/", "/index.gmi"}},
+		{gdn.CopySymlinks, []string{"/", "/index.gmi", "/shared"}},
+	}
+
+	for _, tbl := range tbls {
+		var paths []string
+
+		err := gdn.WalkSymlinks(src, tbl.policy,
+			func(p string, info os.FileInfo, err error) error {
+				rel, _ := filepath.Rel(src, p) // nolint: errcheck // within src
+				rel = filepath.ToSlash(filepath.Join("/", rel))
+
+				if errors.Is(err, gdn.ErrSymlinkLoop) {
+					rel += " (loop)"
+				} else if err != nil {
+					return err
+				}
+
+				paths = append(paths, rel)
+
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("%s: walk encountered an unexpected error: %v",
+				tbl.policy, err)
+		}
+
+		if !equalStrings(paths, tbl.expected) {
+			t.Errorf("%s: walk gave: %v, expecting: %v",
+				tbl.policy, paths, tbl.expected)
+		}
+	}
+}